@@ -2,21 +2,48 @@ package grpcsteps
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/cucumber/godog"
+	"go.nhat.io/grpcmock/must"
 	xreflect "go.nhat.io/grpcmock/reflect"
 	"go.nhat.io/grpcmock/service"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/stats"
 )
 
+// NoTimeout disables the timeout of the requests, see WithTimeout() and WithDefaultTimeout().
+const NoTimeout time.Duration = -1
+
+// defaultTimeout is the timeout of the requests if neither the service nor the client sets one.
+const defaultTimeout = time.Second
+
 // Service contains needed information to form a GRPC request.
 type Service struct {
 	service.Method
 
 	Address     string
 	DialOptions []grpc.DialOption
+	CallOptions []grpc.CallOption
+	// Timeout is the timeout of the requests, the one of the client is used if it is not set.
+	Timeout time.Duration
+	// SendScenario sends the scenario in the ScenarioHeader metadata of the requests.
+	SendScenario bool
+
+	// registration is shared by the methods that are registered together, with the same options.
+	registration *serviceRegistration
+}
+
+// serviceRegistration is the registration of the methods of a service.
+type serviceRegistration struct {
+	id string
 }
 
 // ServiceOption sets up a service.
@@ -27,12 +54,26 @@ type Client struct {
 	services map[string]*Service
 
 	defaultSvcOptions []ServiceOption
+	dialOptions       []grpc.DialOption
+	timeout           time.Duration
+
+	conns       *clientConnPool
+	snapshotDir string
+
+	transcriptOutput    io.Writer
+	transcriptObservers []func(scenario string, e transcriptEntry)
+	tracing             *clientTracing
+
+	readyTimeout time.Duration
+	readyErr     error
 }
 
 // ClientOption sets up a client.
 type ClientOption func(s *Client)
 
 func (c *Client) registerService(id string, svc interface{}, opts ...ServiceOption) {
+	registration := &serviceRegistration{id: id}
+
 	for _, method := range xreflect.FindServiceMethods(svc) {
 		svc := &Service{
 			Method: service.Method{
@@ -42,7 +83,8 @@ func (c *Client) registerService(id string, svc interface{}, opts ...ServiceOpti
 				Input:       method.Input,
 				Output:      method.Output,
 			},
-			Address: ":9090",
+			Address:      ":9090",
+			registration: registration,
 		}
 
 		// Apply default options.
@@ -61,18 +103,60 @@ func (c *Client) registerService(id string, svc interface{}, opts ...ServiceOpti
 
 // RegisterContext registers to godog scenario.
 func (c *Client) RegisterContext(sc *godog.ScenarioContext) {
+	sc.Before(func(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+		ctx, t := withTranscript(ctx, sc)
+
+		t.SetOutput(c.transcriptOutput)
+
+		for _, fn := range c.transcriptObservers {
+			t.Observe(fn)
+		}
+
+		return scenarioToContext(ctx, sc), c.readyErr
+	})
+
+	sc.After(func(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
+		if err != nil {
+			ctx = transcriptFromContext(ctx).reportFailure(ctx)
+		}
+
+		return ctx, nil
+	})
+
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service "([^"]*)" is ready within "([^"]*)"$`, c.iWaitForServiceReady)
+
 	sc.Step(`^I request(?: a)? (?:gRPC|GRPC|grpc)(?: method)? "([^"]*)" with payload:?$`, c.iRequestWithPayloadFromDocString)
 	sc.Step(`^I request(?: a)? (?:gRPC|GRPC|grpc)(?: method)? "([^"]*)" with payload from file "([^"]+)"$`, c.iRequestWithPayloadFromFile)
 	sc.Step(`^I request(?: a)? (?:gRPC|GRPC|grpc)(?: method)? "([^"]*)" with payload from file:$`, c.iRequestWithPayloadFromFileDocString)
+	sc.Step(`^I request(?: a)? (?:gRPC|GRPC|grpc)(?: method)? "([^"]*)" with payload from table:$`, c.iRequestWithPayloadFromTable)
+
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request uses compression "([^"]*)"$`, c.iRequestWithCompression)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request max receive message size is "([^"]*)"$`, c.iRequestWithMaxRecvMsgSize)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request max send message size is "([^"]*)"$`, c.iRequestWithMaxSendMsgSize)
 
 	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with payload:?$`, c.iShouldHaveResponseWithPayloadFromDocString)
 	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with payload from file "([^"]+)"$`, c.iShouldHaveResponseWithPayloadFromFile)
 	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with payload from file:?$`, c.iShouldHaveResponseWithPayloadFromFileDocString)
+	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with payload from table:$`, c.iShouldHaveResponseWithPayloadFromTable)
+	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response matching snapshot "([^"]+)"$`, c.iShouldHaveResponseMatchingSnapshot)
+	sc.Step(`^I should have ([0-9]+) (?:gRPC|GRPC|grpc) stream messages?$`, c.iShouldHaveStreamMessages)
+	sc.Step(`^(?:[tT]he )?(?:gRPC|GRPC|grpc) stream message ([0-9]+) should have payload:?$`, c.iShouldHaveStreamMessageWithPayload)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) stream should contain(?: a)? message:?$`, c.iShouldHaveStreamContainingMessage)
+	sc.Step(`^[aA]ll (?:gRPC|GRPC|grpc) stream messages should match:$`, c.iShouldHaveStreamMessagesMatching)
 	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with code "([^"]*)"$`, c.iShouldHaveResponseWithCode)
 	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with error (?:message )?"([^"]*)"$`, c.iShouldHaveResponseWithErrorMessage)
 	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with code "([^"]*)" and error (?:message )?"([^"]*)"$`, c.iShouldHaveResponseWithCodeAndErrorMessage)
 	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with error(?: message)?:$`, c.iShouldHaveResponseWithErrorMessageFromDocString)
 	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with code "([^"]*)" and error(?: message)?:$`, c.iShouldHaveResponseWithCodeAndErrorMessageFromDocString)
+	sc.Step(`^I should have(?: a)? (?:gRPC|GRPC|grpc) response with code ([0-9]+)$`, c.iShouldHaveResponseWithCode)
+	sc.Step(`^I should not have(?: an?)? (?:gRPC|GRPC|grpc) error$`, c.iShouldNotHaveError)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) response code should be one of "([^"]*)"$`, c.iShouldHaveResponseWithCodeIn)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) (?:response )?error message should contain "([^"]*)"$`, c.iShouldHaveErrorMessageContaining)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) (?:response )?error message should contain:$`, c.iShouldHaveErrorMessageContainingFromDocString)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) (?:response )?error message should match regexp "([^"]*)"$`, c.iShouldHaveErrorMessageMatching)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) (?:response )?error message should match regexp:$`, c.iShouldHaveErrorMessageMatchingFromDocString)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request should have spans:$`, c.iShouldHaveSpans)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request should have(?: a)? span "([^"]*)"$`, c.iShouldHaveSpan)
 
 	registerRequestPlanner(sc)
 }
@@ -88,7 +172,7 @@ func (c *Client) iRequestWithPayload(ctx context.Context, method string, data st
 		return ctx, err
 	}
 
-	return newClientRequestPlannerContext(ctx, svc, payload), nil
+	return newClientRequestPlannerContext(ctx, c, svc, payload), nil
 }
 
 func (c *Client) iRequestWithPayloadFromDocString(ctx context.Context, method string, payload *godog.DocString) (context.Context, error) {
@@ -108,6 +192,51 @@ func (c *Client) iRequestWithPayloadFromFileDocString(ctx context.Context, metho
 	return c.iRequestWithPayloadFromFile(ctx, method, path.Content)
 }
 
+func (c *Client) iRequestWithPayloadFromTable(ctx context.Context, method string, table *godog.Table) (context.Context, error) {
+	svc, ok := c.services[method]
+	if !ok {
+		return ctx, ErrInvalidGRPCMethod
+	}
+
+	in, _, err := newMessageTypes(svc.Method)
+	if err != nil {
+		return ctx, err
+	}
+
+	payload, err := tablePayload(in, table)
+	if err != nil {
+		return ctx, err
+	}
+
+	return c.iRequestWithPayload(ctx, method, payload)
+}
+
+func (c *Client) iRequestWithCompression(ctx context.Context, name string) error {
+	if err := validateCompressor(name); err != nil {
+		return err
+	}
+
+	return planClientRequestWithCallOptions(ctx, grpc.UseCompressor(name))
+}
+
+func (c *Client) iRequestWithMaxRecvMsgSize(ctx context.Context, sizeValue string) error {
+	size, err := toMessageSize(sizeValue)
+	if err != nil {
+		return err
+	}
+
+	return planClientRequestWithCallOptions(ctx, grpc.MaxCallRecvMsgSize(size))
+}
+
+func (c *Client) iRequestWithMaxSendMsgSize(ctx context.Context, sizeValue string) error {
+	size, err := toMessageSize(sizeValue)
+	if err != nil {
+		return err
+	}
+
+	return planClientRequestWithCallOptions(ctx, grpc.MaxCallSendMsgSize(size))
+}
+
 func (c *Client) iShouldHaveResponseWithPayload(ctx context.Context, response string) error {
 	return assertServerResponsePayload(clientRequestFromContext(ctx), response)
 }
@@ -117,6 +246,10 @@ func (c *Client) iShouldHaveResponseWithPayloadFromDocString(ctx context.Context
 }
 
 func (c *Client) iShouldHaveResponseWithPayloadFromFile(ctx context.Context, path string) error {
+	if updateSnapshots() {
+		return assertServerResponseSnapshot(clientRequestFromContext(ctx), path, true)
+	}
+
 	payload, err := os.ReadFile(path) // nolint: gosec
 	if err != nil {
 		return err
@@ -129,6 +262,53 @@ func (c *Client) iShouldHaveResponseWithPayloadFromFileDocString(ctx context.Con
 	return c.iShouldHaveResponseWithPayloadFromFile(ctx, path.Content)
 }
 
+func (c *Client) iShouldHaveResponseMatchingSnapshot(ctx context.Context, name string) error {
+	return assertServerResponseSnapshot(clientRequestFromContext(ctx), filepath.Join(c.snapshotDir, name), updateSnapshots())
+}
+
+func (c *Client) iShouldHaveResponseWithPayloadFromTable(ctx context.Context, table *godog.Table) error {
+	r, ok := clientRequestFromContext(ctx).(*clientRequestInvoker)
+	if !ok {
+		return missingClientRequestPlannerErr()
+	}
+
+	_, out, err := newMessageTypes(r.method)
+	if err != nil {
+		return err
+	}
+
+	payload, err := tablePayload(out, table)
+	if err != nil {
+		return err
+	}
+
+	return c.iShouldHaveResponseWithPayload(ctx, payload)
+}
+
+func (c *Client) iShouldHaveStreamMessages(ctx context.Context, count int) error {
+	return assertServerResponseStreamLength(clientRequestFromContext(ctx), count)
+}
+
+func (c *Client) iShouldHaveStreamMessageWithPayload(ctx context.Context, index int, payload *godog.DocString) error {
+	return assertServerResponseStreamMessage(clientRequestFromContext(ctx), index, payload.Content)
+}
+
+func (c *Client) iShouldHaveStreamContainingMessage(ctx context.Context, payload *godog.DocString) error {
+	return assertServerResponseStreamContains(clientRequestFromContext(ctx), payload.Content)
+}
+
+func (c *Client) iShouldHaveStreamMessagesMatching(ctx context.Context, table *godog.Table) error {
+	predicates := make([]string, 0, len(table.Rows))
+
+	for _, row := range table.Rows {
+		for _, cell := range row.Cells {
+			predicates = append(predicates, cell.Value)
+		}
+	}
+
+	return assertServerResponseStreamMatches(clientRequestFromContext(ctx), predicates)
+}
+
 func (c *Client) iShouldHaveResponseWithCode(ctx context.Context, codeValue string) error {
 	code, err := toStatusCode(codeValue)
 	if err != nil {
@@ -162,10 +342,49 @@ func (c *Client) iShouldHaveResponseWithCodeAndErrorMessageFromDocString(ctx con
 	return c.iShouldHaveResponseWithErrorMessage(ctx, err.Content)
 }
 
+func (c *Client) iShouldNotHaveError(ctx context.Context) error {
+	return assertServerResponseNoError(clientRequestFromContext(ctx))
+}
+
+func (c *Client) iShouldHaveResponseWithCodeIn(ctx context.Context, codeValues string) error {
+	codes, err := toStatusCodes(codeValues)
+	if err != nil {
+		return err
+	}
+
+	return assertServerResponseErrorCodeIn(clientRequestFromContext(ctx), codes)
+}
+
+func (c *Client) iShouldHaveErrorMessageContaining(ctx context.Context, err string) error {
+	return assertServerResponseErrorMessageContains(clientRequestFromContext(ctx), err)
+}
+
+func (c *Client) iShouldHaveErrorMessageContainingFromDocString(ctx context.Context, err *godog.DocString) error {
+	return c.iShouldHaveErrorMessageContaining(ctx, err.Content)
+}
+
+func (c *Client) iShouldHaveErrorMessageMatching(ctx context.Context, pattern string) error {
+	return assertServerResponseErrorMessageMatches(clientRequestFromContext(ctx), pattern)
+}
+
+func (c *Client) iShouldHaveErrorMessageMatchingFromDocString(ctx context.Context, pattern *godog.DocString) error {
+	return c.iShouldHaveErrorMessageMatching(ctx, pattern.Content)
+}
+
+func (c *Client) iShouldHaveSpans(ctx context.Context, tree *godog.DocString) error {
+	return assertClientRequestSpans(ctx, c.tracing, tree.Content)
+}
+
+func (c *Client) iShouldHaveSpan(ctx context.Context, name string) error {
+	return assertClientRequestSpan(ctx, c.tracing, name)
+}
+
 // NewClient initiates a new grpc server extension for testing.
 func NewClient(opts ...ClientOption) *Client {
 	s := &Client{
-		services: make(map[string]*Service),
+		services:         make(map[string]*Service),
+		conns:            &clientConnPool{},
+		transcriptOutput: os.Stderr,
 	}
 
 	for _, o := range opts {
@@ -175,6 +394,52 @@ func NewClient(opts ...ClientOption) *Client {
 	return s
 }
 
+// conn returns the shared connection to the service. The dial options of the client come after the ones of the service.
+func (c *Client) conn(ctx context.Context, svc *Service) (*grpc.ClientConn, error) {
+	opts := make([]grpc.DialOption, 0, len(svc.DialOptions)+len(c.dialOptions))
+	opts = append(opts, svc.DialOptions...)
+	opts = append(opts, c.dialOptions...)
+
+	return c.conns.get(ctx, svc, svc.Address, opts)
+}
+
+// requestTimeout returns the timeout of the requests to the service, unless the scenario sets one.
+func (c *Client) requestTimeout(svc *Service) time.Duration {
+	timeout := defaultTimeout
+
+	if c.timeout != 0 {
+		timeout = c.timeout
+	}
+
+	if svc.Timeout != 0 {
+		timeout = svc.Timeout
+	}
+
+	if timeout < 0 {
+		return 0
+	}
+
+	return timeout
+}
+
+// TracerProvider returns the tracer provider of the client requests, see WithTracing(). The spans that the application
+// under test starts with it are in the traces of the requests, if it runs in the same process. It does not trace
+// anything if the tracing is not enabled.
+func (c *Client) TracerProvider() trace.TracerProvider {
+	if c.tracing == nil {
+		return trace.NewNoopTracerProvider()
+	}
+
+	return c.tracing.provider
+}
+
+// Close closes the connections to the services. The requests to the same address with the same dial options share a
+// connection across the scenarios, it is closed after the suite if the client is registered with
+// RegisterSuiteContext().
+func (c *Client) Close() error {
+	return c.conns.close()
+}
+
 // RegisterServiceFromInstance registers a grpc server by its interface.
 func RegisterServiceFromInstance(id string, svc interface{}, opts ...ServiceOption) ClientOption {
 	return func(c *Client) {
@@ -198,6 +463,63 @@ func WithDefaultServiceOptions(opts ...ServiceOption) ClientOption {
 	}
 }
 
+// WithDefaultTimeout sets the timeout of the requests to all the services, 1 second if it is not set. Use NoTimeout to
+// disable it. The services and the scenarios could set another one.
+func WithDefaultTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithSnapshotDir sets the directory of the response snapshots, the current directory if it is not set.
+func WithSnapshotDir(dir string) ClientOption {
+	return func(c *Client) {
+		c.snapshotDir = dir
+	}
+}
+
+// WithTranscriptOutput sets where the transcript of a failed scenario is written, the standard error if it is not set.
+// Use nil to only attach it to the cucumber report. The transcript has the grpc requests that the client sends and the
+// ones that the mocked services of the ExternalServiceManager receive.
+func WithTranscriptOutput(w io.Writer) ClientOption {
+	return func(c *Client) {
+		c.transcriptOutput = w
+	}
+}
+
+// WithTracing starts a span per request and sends its trace context with the W3C traceparent header, so the mocked
+// services of the ExternalServiceManager could check that the application under test propagates it. The spans are kept in
+// memory, see TracerProvider().
+func WithTracing() ClientOption {
+	return func(c *Client) {
+		c.tracing = newClientTracing()
+	}
+}
+
+// WithUnaryInterceptor adds an interceptor to the unary requests of all the services. The interceptors are chained in
+// the order they are added, the scenario that sends the request is in the context, see ScenarioFromContext().
+func WithUnaryInterceptor(i grpc.UnaryClientInterceptor) ClientOption {
+	return func(c *Client) {
+		c.dialOptions = append(c.dialOptions, grpc.WithChainUnaryInterceptor(i))
+	}
+}
+
+// WithStreamInterceptor adds an interceptor to the stream requests of all the services. The interceptors are chained in
+// the order they are added, the scenario that sends the request is in the context, see ScenarioFromContext().
+func WithStreamInterceptor(i grpc.StreamClientInterceptor) ClientOption {
+	return func(c *Client) {
+		c.dialOptions = append(c.dialOptions, grpc.WithChainStreamInterceptor(i))
+	}
+}
+
+// WithStatsHandler adds a stats handler to the connections of all the services. The scenario that sends the request is
+// in the context of the rpc stats, see ScenarioFromContext().
+func WithStatsHandler(h stats.Handler) ClientOption {
+	return func(c *Client) {
+		c.dialOptions = append(c.dialOptions, grpc.WithStatsHandler(h))
+	}
+}
+
 // AddrProvider provides a net address.
 type AddrProvider interface {
 	Addr() net.Addr
@@ -228,3 +550,55 @@ func WithDialOptions(opts ...grpc.DialOption) ServiceOption {
 		s.DialOptions = opts
 	}
 }
+
+// WithTimeout sets the timeout of the requests to the service, use NoTimeout to disable it. The scenarios could set
+// another one.
+func WithTimeout(d time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.Timeout = d
+	}
+}
+
+// WithScenarioHeader sends the scenario in the ScenarioHeader metadata of the requests to the service, so the mocked
+// services could tell the concurrent scenarios apart. The services of the external service manager have it, see
+// WithExternalService(). An application under test needs it if it calls the mocked services and forwards the header.
+func WithScenarioHeader() ServiceOption {
+	return func(s *Service) {
+		s.SendScenario = true
+	}
+}
+
+// WithCallOption adds a call option to the requests to the service.
+func WithCallOption(o grpc.CallOption) ServiceOption {
+	return func(s *Service) {
+		s.CallOptions = append(s.CallOptions, o)
+	}
+}
+
+// WithCompression compresses the requests to the service with a registered compressor, for example "gzip". It panics if
+// the compressor is not registered. The scenarios could use another one.
+func WithCompression(name string) ServiceOption {
+	must.NotFail(validateCompressor(name))
+
+	return WithCallOption(grpc.UseCompressor(name))
+}
+
+// WithMaxRecvMsgSize sets the maximum size in bytes of the responses from the service.
+func WithMaxRecvMsgSize(size int) ServiceOption {
+	return WithCallOption(grpc.MaxCallRecvMsgSize(size))
+}
+
+// WithMaxSendMsgSize sets the maximum size in bytes of the requests to the service.
+func WithMaxSendMsgSize(size int) ServiceOption {
+	return WithCallOption(grpc.MaxCallSendMsgSize(size))
+}
+
+// validateCompressor checks that the compressor is registered, gzip is registered by this package. Use
+// encoding.RegisterCompressor() to register a custom one.
+func validateCompressor(name string) error {
+	if name == encoding.Identity || encoding.GetCompressor(name) != nil {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnknownCompressor, name)
+}
@@ -1,20 +1,36 @@
 package grpcsteps
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"strings"
 
+	"github.com/PaesslerAG/gval"
+	"github.com/PaesslerAG/jsonpath"
 	"github.com/swaggest/assertjson"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// assertServerResponsePayload asserts the payload of the response. The messages that a stream receives before an error
+// are asserted too, the error could be asserted by the other steps.
 func assertServerResponsePayload(req clientRequest, expected string) error {
 	actual, err := req.Do()
-	if err != nil {
+	if actual == nil {
 		return fmt.Errorf("an error occurred while send grpc request: %w", err)
 	}
 
-	return assertjson.FailNotEqual([]byte(expected), actual)
+	if aErr := assertjson.FailNotEqual([]byte(expected), actual); aErr != nil {
+		if err != nil {
+			return fmt.Errorf("the stream ended with an error: %s\n%w", err.Error(), aErr)
+		}
+
+		return aErr
+	}
+
+	return nil
 }
 
 func assertServerResponseErrorCode(req clientRequest, expected codes.Code) error {
@@ -54,3 +70,164 @@ func assertServerResponseErrorMessage(req clientRequest, expected string) error
 
 	return nil
 }
+
+func assertServerResponseErrorCodeIn(req clientRequest, expected []codes.Code) error {
+	actual := codes.OK
+
+	_, err := req.Do()
+	if err != nil {
+		actual = status.Convert(err).Code()
+	}
+
+	for _, code := range expected {
+		if code == actual {
+			return nil
+		}
+	}
+
+	if err == nil {
+		return fmt.Errorf("got no error, want one of %q", expected) // nolint: goerr113
+	}
+
+	return fmt.Errorf("got %w, want one of %q", err, expected)
+}
+
+func assertServerResponseNoError(req clientRequest) error {
+	if _, err := req.Do(); err != nil {
+		return fmt.Errorf("got %w, want no error", err)
+	}
+
+	return nil
+}
+
+func assertServerResponseErrorMessageContains(req clientRequest, expected string) error {
+	_, err := req.Do()
+	if err == nil {
+		return fmt.Errorf("got no error, want an error containing %q", expected) // nolint: goerr113
+	}
+
+	actual := status.Convert(err).Message()
+
+	if !strings.Contains(actual, expected) {
+		return fmt.Errorf("unexpected error message, got %q, want it to contain %q", actual, expected) // nolint: goerr113
+	}
+
+	return nil
+}
+
+func assertServerResponseErrorMessageMatches(req clientRequest, pattern string) error {
+	expected, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	_, err = req.Do()
+	if err == nil {
+		return fmt.Errorf("got no error, want an error matching %q", pattern) // nolint: goerr113
+	}
+
+	actual := status.Convert(err).Message()
+
+	if !expected.MatchString(actual) {
+		return fmt.Errorf("unexpected error message, got %q, want it to match %q", actual, pattern) // nolint: goerr113
+	}
+
+	return nil
+}
+
+// streamMessages returns the messages of a stream response.
+func streamMessages(req clientRequest) ([]json.RawMessage, error) {
+	actual, err := req.Do()
+	if actual == nil {
+		return nil, fmt.Errorf("an error occurred while send grpc request: %w", err)
+	}
+
+	var messages []json.RawMessage
+
+	if err := json.Unmarshal(actual, &messages); err != nil {
+		return nil, ErrNotStreamResponse
+	}
+
+	return messages, nil
+}
+
+func assertServerResponseStreamLength(req clientRequest, expected int) error {
+	messages, err := streamMessages(req)
+	if err != nil {
+		return err
+	}
+
+	if actual := len(messages); actual != expected {
+		return fmt.Errorf("got %d stream messages, want %d", actual, expected) // nolint: goerr113
+	}
+
+	return nil
+}
+
+// assertServerResponseStreamMessage asserts the payload of a message of a stream response, the first message is 1.
+func assertServerResponseStreamMessage(req clientRequest, index int, expected string) error {
+	messages, err := streamMessages(req)
+	if err != nil {
+		return err
+	}
+
+	if index < 1 || index > len(messages) {
+		return fmt.Errorf("stream message %d not found, got %d stream messages", index, len(messages)) // nolint: goerr113
+	}
+
+	if err := assertjson.FailNotEqual([]byte(expected), messages[index-1]); err != nil {
+		return fmt.Errorf("stream message %d is %w", index, err)
+	}
+
+	return nil
+}
+
+func assertServerResponseStreamContains(req clientRequest, expected string) error {
+	messages, err := streamMessages(req)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range messages {
+		if assertjson.FailNotEqual([]byte(expected), m) == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("none of the %d stream messages matches:\n%s", len(messages), expected) // nolint: goerr113
+}
+
+// assertServerResponseStreamMatches asserts that all the messages of a stream response match the JSONPath predicates,
+// like `$.id > 0`.
+func assertServerResponseStreamMatches(req clientRequest, predicates []string) error {
+	messages, err := streamMessages(req)
+	if err != nil {
+		return err
+	}
+
+	lang := gval.Full(jsonpath.Language())
+
+	for _, predicate := range predicates {
+		eval, err := lang.NewEvaluable(predicate)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidPredicate, err.Error())
+		}
+
+		for i, m := range messages {
+			var v interface{}
+
+			_ = json.Unmarshal(m, &v) // nolint: errcheck
+
+			matched, err := eval.EvalBool(context.Background(), v)
+			if err != nil {
+				return fmt.Errorf("stream message %d does not match %q: %w", i+1, predicate, err)
+			}
+
+			if !matched {
+				return fmt.Errorf("stream message %d does not match %q:\n%s", i+1, predicate, m) // nolint: goerr113
+			}
+		}
+	}
+
+	return nil
+}
@@ -54,6 +54,29 @@ func TestAssertServerResponsePayload(t *testing.T) {
 				return []byte(`{"name": "john"}`), nil
 			},
 		},
+		{
+			scenario: "partial stream",
+			expected: `[{"name": "john"}]`,
+			request: func() ([]byte, error) {
+				return []byte(`[{"name": "john"}]`), status.Error(codes.Aborted, "quota exceeded")
+			},
+		},
+		{
+			scenario: "different partial stream",
+			expected: `[{"name": "john"}]`,
+			request: func() ([]byte, error) {
+				return []byte(`[{"name": "foobar"}]`), status.Error(codes.Aborted, "quota exceeded")
+			},
+			expectedError: `the stream ended with an error: rpc error: code = Aborted desc = quota exceeded
+not equal:
+ [
+   {
+-    "name": "john"
++    "name": "foobar"
+   }
+ ]
+`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -185,6 +208,277 @@ func TestAssertServerResponseErrorMessage(t *testing.T) {
 	}
 }
 
+func TestAssertServerResponseFlexibleError(t *testing.T) {
+	t.Parallel()
+
+	noError := func() ([]byte, error) {
+		return []byte(`{}`), nil
+	}
+
+	unavailable := func() ([]byte, error) {
+		return nil, status.Error(codes.Unavailable, "request 42 failed")
+	}
+
+	testCases := []struct {
+		scenario      string
+		request       clientRequestDoer
+		assert        func(req clientRequest) error
+		expectedError string
+	}{
+		{
+			scenario: "code in list",
+			request:  unavailable,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorCodeIn(req, []codes.Code{codes.DeadlineExceeded, codes.Unavailable})
+			},
+		},
+		{
+			scenario: "code not in list",
+			request:  unavailable,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorCodeIn(req, []codes.Code{codes.DeadlineExceeded, codes.Internal})
+			},
+			expectedError: `got rpc error: code = Unavailable desc = request 42 failed, want one of ["DeadlineExceeded" "Internal"]`,
+		},
+		{
+			scenario: "no error and ok in list",
+			request:  noError,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorCodeIn(req, []codes.Code{codes.OK, codes.NotFound})
+			},
+		},
+		{
+			scenario: "no error and ok not in list",
+			request:  noError,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorCodeIn(req, []codes.Code{codes.NotFound})
+			},
+			expectedError: `got no error, want one of ["NotFound"]`,
+		},
+		{
+			scenario: "no error",
+			request:  noError,
+			assert:   assertServerResponseNoError,
+		},
+		{
+			scenario:      "has error",
+			request:       unavailable,
+			assert:        assertServerResponseNoError,
+			expectedError: `got rpc error: code = Unavailable desc = request 42 failed, want no error`,
+		},
+		{
+			scenario: "message contains",
+			request:  unavailable,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorMessageContains(req, "failed")
+			},
+		},
+		{
+			scenario: "message does not contain",
+			request:  unavailable,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorMessageContains(req, "refused")
+			},
+			expectedError: `unexpected error message, got "request 42 failed", want it to contain "refused"`,
+		},
+		{
+			scenario: "no error to contain",
+			request:  noError,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorMessageContains(req, "failed")
+			},
+			expectedError: `got no error, want an error containing "failed"`,
+		},
+		{
+			scenario: "message matches",
+			request:  unavailable,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorMessageMatches(req, `^request [0-9]+ failed$`)
+			},
+		},
+		{
+			scenario: "message does not match",
+			request:  unavailable,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorMessageMatches(req, `^request [a-z]+ failed$`)
+			},
+			expectedError: `unexpected error message, got "request 42 failed", want it to match "^request [a-z]+ failed$"`,
+		},
+		{
+			scenario: "no error to match",
+			request:  noError,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorMessageMatches(req, `failed`)
+			},
+			expectedError: `got no error, want an error matching "failed"`,
+		},
+		{
+			scenario: "invalid regexp",
+			request:  unavailable,
+			assert: func(req clientRequest) error {
+				return assertServerResponseErrorMessageMatches(req, `(`)
+			},
+			expectedError: "error parsing regexp: missing closing ): `(`",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.assert(tc.request)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestAssertServerResponseStream(t *testing.T) {
+	t.Parallel()
+
+	stream := func() ([]byte, error) {
+		return []byte(`[{"id": 1, "name": "foo"}, {"id": 2, "name": "bar"}]`), nil
+	}
+
+	testCases := []struct {
+		scenario      string
+		request       clientRequestDoer
+		assert        func(req clientRequest) error
+		expectedError string
+	}{
+		{
+			scenario: "has error",
+			request: func() ([]byte, error) {
+				return nil, errors.New("request error")
+			},
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamLength(req, 2)
+			},
+			expectedError: `an error occurred while send grpc request: request error`,
+		},
+		{
+			scenario: "not a stream",
+			request: func() ([]byte, error) {
+				return []byte(`{"id": 1}`), nil
+			},
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamLength(req, 1)
+			},
+			expectedError: `grpc response is not a stream`,
+		},
+		{
+			scenario: "different length",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamLength(req, 3)
+			},
+			expectedError: `got 2 stream messages, want 3`,
+		},
+		{
+			scenario: "same length",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamLength(req, 2)
+			},
+		},
+		{
+			scenario: "message not found",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamMessage(req, 3, `{}`)
+			},
+			expectedError: `stream message 3 not found, got 2 stream messages`,
+		},
+		{
+			scenario: "different message",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamMessage(req, 2, `{"id": 2, "name": "baz"}`)
+			},
+			expectedError: `stream message 2 is not equal:
+ {
+   "id": 2,
+-  "name": "baz"
++  "name": "bar"
+ }
+`,
+		},
+		{
+			scenario: "same message",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamMessage(req, 2, `{"id": 2, "name": "<ignore-diff>"}`)
+			},
+		},
+		{
+			scenario: "not contain message",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamContains(req, `{"id": 3}`)
+			},
+			expectedError: "none of the 2 stream messages matches:\n{\"id\": 3}",
+		},
+		{
+			scenario: "contain message",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamContains(req, `{"id": 2, "name": "bar"}`)
+			},
+		},
+		{
+			scenario: "invalid predicate",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamMatches(req, []string{`$.id >`})
+			},
+			expectedError: "invalid predicate: parsing error: $.id >\t:1:7 - 1:7 unexpected EOF while scanning extensions",
+		},
+		{
+			scenario: "missing field",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamMatches(req, []string{`$.locale == "en-US"`})
+			},
+			expectedError: `stream message 1 does not match "$.locale == \"en-US\"": unknown key locale`,
+		},
+		{
+			scenario: "not match",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamMatches(req, []string{`$.id > 0`, `$.name == "foo"`})
+			},
+			expectedError: "stream message 2 does not match \"$.name == \\\"foo\\\"\":\n{\"id\": 2, \"name\": \"bar\"}",
+		},
+		{
+			scenario: "match",
+			request:  stream,
+			assert: func(req clientRequest) error {
+				return assertServerResponseStreamMatches(req, []string{`$.id > 0`, `$.name =~ "^(foo|bar)$"`})
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.assert(tc.request)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
 type clientRequestDoer func() ([]byte, error)
 
 func (d clientRequestDoer) Do() ([]byte, error) {
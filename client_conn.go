@@ -0,0 +1,72 @@
+package grpcsteps
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// clientConn is a connection that the requests to the same service at the same address share. The dial options belong to
+// the registration of the service, so they are not compared, the ones that are built for every request do not open
+// another connection.
+type clientConn struct {
+	service *Service
+	address string
+	conn    *grpc.ClientConn
+}
+
+// clientConnPool keeps the connections of a client until it is closed, so the scenarios do not dial the services for
+// every request.
+type clientConnPool struct {
+	conns []*clientConn
+
+	mu sync.Mutex
+}
+
+func (p *clientConnPool) get(ctx context.Context, svc *Service, address string, dialOptions []grpc.DialOption) (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, c := range p.conns {
+		if sameService(c.service, svc) && c.address == address {
+			return c.conn, nil
+		}
+	}
+
+	conn, err := grpc.DialContext(ctx, address, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	p.conns = append(p.conns, &clientConn{
+		service: svc,
+		address: address,
+		conn:    conn,
+	})
+
+	return conn, nil
+}
+
+// close closes all the connections and returns the first error.
+func (p *clientConnPool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+
+	for _, c := range p.conns {
+		if cerr := c.conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	p.conns = nil
+
+	return err
+}
+
+// sameService tells whether the services are the same or are registered together, so they have the same dial options.
+func sameService(a, b *Service) bool {
+	return a == b || (a.registration != nil && a.registration == b.registration)
+}
@@ -0,0 +1,71 @@
+package grpcsteps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestClientConnPool(t *testing.T) {
+	t.Parallel()
+
+	// The dial options are built for every request, like in a helper.
+	opts := func() []grpc.DialOption {
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+
+	registration := &serviceRegistration{id: "grpctest.ItemService"}
+
+	svc1 := &Service{Address: "localhost:9090", registration: registration}
+	svc2 := &Service{Address: "localhost:9090", registration: registration}
+	svc3 := &Service{Address: "localhost:9090", registration: &serviceRegistration{id: "grpctest.ItemService"}}
+
+	p := &clientConnPool{}
+
+	conn1, err := p.get(context.Background(), svc1, svc1.Address, opts())
+	require.NoError(t, err)
+
+	conn2, err := p.get(context.Background(), svc1, svc1.Address, opts())
+	require.NoError(t, err)
+
+	conn3, err := p.get(context.Background(), svc1, "localhost:9091", opts())
+	require.NoError(t, err)
+
+	conn4, err := p.get(context.Background(), svc2, svc2.Address, opts())
+	require.NoError(t, err)
+
+	conn5, err := p.get(context.Background(), svc3, svc3.Address, opts())
+	require.NoError(t, err)
+
+	assert.Same(t, conn1, conn2)
+	assert.NotSame(t, conn1, conn3)
+	assert.Same(t, conn1, conn4)
+	assert.NotSame(t, conn1, conn5)
+	assert.Len(t, p.conns, 3)
+
+	assert.NoError(t, p.close())
+	assert.Empty(t, p.conns)
+
+	// The closed connections are not reused.
+	conn6, err := p.get(context.Background(), svc1, svc1.Address, opts())
+	require.NoError(t, err)
+
+	assert.NotSame(t, conn1, conn6)
+	assert.NoError(t, p.close())
+}
+
+func TestClientConnPool_DialError(t *testing.T) {
+	t.Parallel()
+
+	p := &clientConnPool{}
+
+	// Missing transport credentials.
+	_, err := p.get(context.Background(), &Service{}, "localhost:9090", nil)
+
+	assert.Error(t, err)
+	assert.Empty(t, p.conns)
+}
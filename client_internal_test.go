@@ -3,9 +3,11 @@ package grpcsteps
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/cucumber/godog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/godogx/grpcsteps/internal/grpctest"
@@ -84,6 +86,105 @@ func TestClient_iShouldHaveResponseWithCodeAndErrorMessageFromDocString_InvalidC
 	assert.EqualError(t, err, expected)
 }
 
+func TestClient_RequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario      string
+		clientOptions []ClientOption
+		svcOptions    []ServiceOption
+		stepTimeout   *time.Duration
+		expected      time.Duration
+	}{
+		{
+			scenario: "built-in",
+			expected: time.Second,
+		},
+		{
+			scenario:      "client",
+			clientOptions: []ClientOption{WithDefaultTimeout(5 * time.Second)},
+			expected:      5 * time.Second,
+		},
+		{
+			scenario:      "client without timeout",
+			clientOptions: []ClientOption{WithDefaultTimeout(NoTimeout)},
+			expected:      0,
+		},
+		{
+			scenario:      "service over client",
+			clientOptions: []ClientOption{WithDefaultTimeout(5 * time.Second)},
+			svcOptions:    []ServiceOption{WithTimeout(3 * time.Second)},
+			expected:      3 * time.Second,
+		},
+		{
+			scenario:      "service over client without timeout",
+			clientOptions: []ClientOption{WithDefaultTimeout(NoTimeout)},
+			svcOptions:    []ServiceOption{WithTimeout(2 * time.Second)},
+			expected:      2 * time.Second,
+		},
+		{
+			scenario:      "service without timeout",
+			clientOptions: []ClientOption{WithDefaultTimeout(5 * time.Second)},
+			svcOptions:    []ServiceOption{WithTimeout(NoTimeout)},
+			expected:      0,
+		},
+		{
+			scenario:    "step over service without timeout",
+			svcOptions:  []ServiceOption{WithTimeout(NoTimeout)},
+			stepTimeout: durationPtr(100 * time.Millisecond),
+			expected:    100 * time.Millisecond,
+		},
+		{
+			scenario:    "step without timeout",
+			svcOptions:  []ServiceOption{WithTimeout(2 * time.Second)},
+			stepTimeout: durationPtr(0),
+			expected:    0,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			opts := append([]ClientOption{RegisterService(grpctest.RegisterItemServiceServer, tc.svcOptions...)}, tc.clientOptions...)
+
+			ctx, err := NewClient(opts...).
+				iRequestWithPayload(context.Background(), "/grpctest.ItemService/GetItem", `{"id": 42}`)
+			require.NoError(t, err)
+
+			if tc.stepTimeout != nil {
+				require.NoError(t, requestPlannerFromContext(ctx).WithTimeout(*tc.stepTimeout))
+			}
+
+			r := clientRequestFromContext(ctx).(*clientRequestInvoker) // nolint: errcheck
+
+			assert.Equal(t, tc.expected, r.timeout)
+		})
+	}
+}
+
+func TestClient_iRequestWithCompression_UnknownCompressor(t *testing.T) {
+	t.Parallel()
+
+	err := NewClient().iRequestWithCompression(context.Background(), "unknown")
+
+	assert.EqualError(t, err, `unknown compressor: "unknown"`)
+	assert.Panics(t, func() {
+		WithCompression("unknown")
+	})
+}
+
+func TestClient_iRequestWithMessageSize_Error(t *testing.T) {
+	t.Parallel()
+
+	c := NewClient()
+
+	assert.EqualError(t, c.iRequestWithMaxRecvMsgSize(context.Background(), "large"), `invalid message size: "large"`)
+	assert.EqualError(t, c.iRequestWithMaxSendMsgSize(context.Background(), "large"), `invalid message size: "large"`)
+	assert.ErrorIs(t, c.iRequestWithMaxSendMsgSize(context.Background(), "1MB"), ErrNoClientRequestInContext)
+}
+
 func TestWithAddr(t *testing.T) {
 	t.Parallel()
 
@@ -105,3 +206,7 @@ func TestWithAddressProvider(t *testing.T) {
 
 	assert.Equal(t, addr, c.services["/grpctest.ItemService/ListItems"].Address)
 }
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
@@ -0,0 +1,31 @@
+//go:build go1.21
+// +build go1.21
+
+package grpcsteps
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger logs the grpc requests of the scenarios, the ones that the client sends and the ones that the mocked
+// services of the ExternalServiceManager receive, with their headers, payloads, status and duration.
+func WithLogger(l *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.transcriptObservers = append(c.transcriptObservers, func(scenario string, e transcriptEntry) {
+			l.LogAttrs(context.Background(), slog.LevelInfo, "grpc request",
+				slog.String("scenario", scenario),
+				slog.String("source", e.Source),
+				slog.String("method", e.Method),
+				slog.String("address", e.Address),
+				slog.Any("header", e.Header),
+				slog.String("request", string(e.Request)),
+				slog.Any("response_header", e.ResponseHeader),
+				slog.String("response", string(e.Response)),
+				slog.String("code", e.Code.String()),
+				slog.String("message", e.Message),
+				slog.Duration("duration", e.Duration),
+			)
+		})
+	}
+}
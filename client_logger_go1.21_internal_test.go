@@ -0,0 +1,51 @@
+//go:build go1.21
+// +build go1.21
+
+package grpcsteps
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	}))
+
+	c := NewClient(WithLogger(l))
+
+	tr := &transcript{scenario: "Get item"}
+
+	tr.Observe(c.transcriptObservers[0])
+	tr.Record(transcriptEntry{
+		Source:   transcriptSourceClient,
+		Method:   "/grpctest.ItemService/GetItem",
+		Address:  "localhost:9090",
+		Header:   metadata.Pairs("locale", "en-US"),
+		Request:  []byte(`{"id":42}`),
+		Code:     codes.NotFound,
+		Message:  "item not found",
+		Duration: 3 * time.Millisecond,
+	})
+
+	expected := `level=INFO msg="grpc request" scenario="Get item" source=client method=/grpctest.ItemService/GetItem address=localhost:9090 header=map[locale:[en-US]] request="{\"id\":42}" response_header=map[] response="" code=NotFound message="item not found" duration=3ms` + "\n"
+
+	assert.Equal(t, expected, buf.String())
+}
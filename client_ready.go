@@ -0,0 +1,140 @@
+package grpcsteps
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cucumber/godog"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const readinessPollInterval = 50 * time.Millisecond
+
+// RegisterSuiteContext registers to godog suite. If the client has WithWaitForReady(), it waits for all the services
+// before the suite, and the scenarios fail if any of them is not ready. The connections are closed after the suite.
+func (c *Client) RegisterSuiteContext(sc *godog.TestSuiteContext) {
+	sc.AfterSuite(func() {
+		_ = c.Close() // nolint: errcheck
+	})
+
+	sc.BeforeSuite(func() {
+		if c.readyTimeout <= 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.readyTimeout)
+		defer cancel()
+
+		c.readyErr = c.waitForAllReady(ctx, c.readyTimeout)
+	})
+}
+
+func (c *Client) iWaitForServiceReady(ctx context.Context, name, timeoutValue string) error {
+	timeout, err := time.ParseDuration(timeoutValue)
+	if err != nil {
+		return err
+	}
+
+	for _, svc := range c.services {
+		if svc.ServiceName != name {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return c.waitForReady(ctx, svc, name, timeout)
+	}
+
+	return fmt.Errorf("%w: %s", ErrGRPCServiceNotFound, name)
+}
+
+// waitForAllReady waits for all the addresses of the services.
+func (c *Client) waitForAllReady(ctx context.Context, timeout time.Duration) error {
+	services := make(map[string]*Service)
+
+	for _, svc := range c.services {
+		if _, ok := services[svc.Address]; !ok {
+			services[svc.Address] = svc
+		}
+	}
+
+	addresses := make([]string, 0, len(services))
+
+	for addr := range services {
+		addresses = append(addresses, addr)
+	}
+
+	sort.Strings(addresses)
+
+	for _, addr := range addresses {
+		if err := c.waitForReady(ctx, services[addr], "", timeout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// waitForReady polls the health of the service until it is SERVING. A server that does not serve the health service is
+// ready as soon as it answers.
+func (c *Client) waitForReady(ctx context.Context, svc *Service, name string, timeout time.Duration) error {
+	conn, err := c.conn(ctx, svc)
+	if err != nil {
+		return fmt.Errorf("%w within %s, address %q: %s", ErrGRPCServiceNotReady, timeout, svc.Address, err.Error())
+	}
+
+	client := healthpb.NewHealthClient(conn)
+
+	for {
+		err := checkHealth(ctx, client, name)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w within %s, address %q: %s", ErrGRPCServiceNotReady, timeout, svc.Address, err.Error())
+
+		case <-time.After(readinessPollInterval):
+		}
+	}
+}
+
+func checkHealth(ctx context.Context, client healthpb.HealthClient, name string) error {
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: name})
+
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.Unimplemented:
+		return nil
+
+	case codes.NotFound:
+		if name != "" {
+			// The health service does not know the service, the server decides.
+			return checkHealth(ctx, client, "")
+		}
+
+		return err
+
+	default:
+		return err
+	}
+
+	if s := resp.GetStatus(); s != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("health status is %s", s) // nolint: goerr113
+	}
+
+	return nil
+}
+
+// WithWaitForReady waits for all the services before the suite, see RegisterSuiteContext().
+func WithWaitForReady(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.readyTimeout = timeout
+	}
+}
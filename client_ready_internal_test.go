@@ -0,0 +1,63 @@
+package grpcsteps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestWaitForReady_Health(t *testing.T) {
+	t.Parallel()
+
+	h := health.NewServer()
+	h.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, h)
+
+	s := ServeInMemory(srv)
+	t.Cleanup(s.Close)
+
+	svc := &Service{}
+
+	WithInMemoryServer(s)(svc)
+
+	c := NewClient()
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := c.waitForReady(ctx, svc, "grpctest.ItemService", 100*time.Millisecond)
+	expected := `grpc service is not ready within 100ms, address "bufconn": health status is NOT_SERVING`
+
+	assert.EqualError(t, err, expected)
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		h.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	})
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The health service does not know the service, so the server is checked.
+	assert.NoError(t, c.waitForReady(ctx, svc, "grpctest.ItemService", time.Second))
+}
+
+func TestClient_IWaitForServiceReady_Error(t *testing.T) {
+	t.Parallel()
+
+	c := NewClient()
+
+	assert.EqualError(t, c.iWaitForServiceReady(context.Background(), "grpctest.ItemService", "1"),
+		`time: missing unit in duration "1"`)
+	assert.EqualError(t, c.iWaitForServiceReady(context.Background(), "grpctest.UnknownService", "1s"),
+		`grpc service not found: grpctest.UnknownService`)
+}
@@ -0,0 +1,143 @@
+package grpcsteps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// UpdateSnapshotsEnv is the environment variable that turns on the update mode of the snapshots, like the
+// -grpcsteps.update flag.
+const UpdateSnapshotsEnv = "GRPCSTEPS_UPDATE"
+
+var updateSnapshotsFlag = flag.Bool("grpcsteps.update", false, "update the grpc response snapshots and payload files")
+
+// updateSnapshots tells whether the snapshots are rewritten with the actual responses instead of being compared.
+func updateSnapshots() bool {
+	if *updateSnapshotsFlag {
+		return true
+	}
+
+	update, _ := strconv.ParseBool(os.Getenv(UpdateSnapshotsEnv)) // nolint: errcheck
+
+	return update
+}
+
+// assertServerResponseSnapshot compares the response with the payload in a file. In the update mode, the file is
+// rewritten with the response instead, the fields with "<ignore-diff>" are kept as they are. The file is not rewritten if
+// the request fails, even with a partial stream. Only the responses of the client are snapshots, the payload files of
+// the mocked services are not rewritten.
+func assertServerResponseSnapshot(req clientRequest, path string, update bool) error {
+	if !update {
+		expected, err := os.ReadFile(path) // nolint: gosec
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%w, run the tests with -grpcsteps.update or %s=1 to create it", err, UpdateSnapshotsEnv)
+			}
+
+			return err
+		}
+
+		return assertServerResponsePayload(req, string(expected))
+	}
+
+	actual, err := req.Do()
+	if actual == nil {
+		return fmt.Errorf("an error occurred while send grpc request: %w", err)
+	}
+
+	if err != nil {
+		return fmt.Errorf("the stream ended with an error, the snapshot is not updated: %w", err)
+	}
+
+	snapshot, err := snapshotPayload(path, actual)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { // nolint: gosec
+		return err
+	}
+
+	return os.WriteFile(path, snapshot, 0o644) // nolint: gosec
+}
+
+// snapshotPayload returns the new payload of a snapshot, the ignored fields of the current snapshot, if any, are kept.
+// The numbers are kept as they are, so the int64 values are not rounded.
+func snapshotPayload(path string, actual []byte) ([]byte, error) {
+	var result interface{}
+
+	if err := unmarshalNumbers(actual, &result); err != nil {
+		return nil, err
+	}
+
+	if current, err := os.ReadFile(path); err == nil { // nolint: gosec
+		var expected interface{}
+
+		// A snapshot that is not JSON is overwritten.
+		if unmarshalNumbers(current, &expected) == nil {
+			result = keepIgnoredDiff(expected, result)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+
+	if err := enc.Encode(result); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func unmarshalNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	return dec.Decode(v)
+}
+
+// keepIgnoredDiff keeps the "<ignore-diff>" of the expected value in the actual one.
+func keepIgnoredDiff(expected, actual interface{}) interface{} {
+	if s, ok := expected.(string); ok && s == ignoreDiff {
+		return expected
+	}
+
+	switch actual := actual.(type) {
+	case map[string]interface{}:
+		expected, ok := expected.(map[string]interface{})
+		if !ok {
+			return actual
+		}
+
+		for k, v := range actual {
+			if e, ok := expected[k]; ok {
+				actual[k] = keepIgnoredDiff(e, v)
+			}
+		}
+
+	case []interface{}:
+		expected, ok := expected.([]interface{})
+		if !ok {
+			return actual
+		}
+
+		for i := range actual {
+			if i < len(expected) {
+				actual[i] = keepIgnoredDiff(expected[i], actual[i])
+			}
+		}
+	}
+
+	return actual
+}
@@ -0,0 +1,231 @@
+package grpcsteps
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAssertServerResponseSnapshot(t *testing.T) {
+	t.Parallel()
+
+	response := func() ([]byte, error) {
+		return []byte(`{"id": 42, "name": "Item #42", "create_time": {"seconds": 1700000000}}`), nil
+	}
+
+	testCases := []struct {
+		scenario         string
+		snapshot         string
+		request          clientRequestDoer
+		update           bool
+		expectedSnapshot string
+		expectedError    string
+	}{
+		{
+			scenario: "same as snapshot",
+			snapshot: `{"id": 42, "name": "Item #42", "create_time": "<ignore-diff>"}`,
+			request:  response,
+		},
+		{
+			scenario: "different from snapshot",
+			snapshot: `{"id": 42, "name": "Item #42", "create_time": "<ignore-diff>", "locale": "en-US"}`,
+			request:  response,
+			expectedError: `not equal:
+ {
+   "create_time": "<ignore-diff>",
+   "id": 42,
+-  "locale": "en-US",
+   "name": "Item #42"
+ }
+`,
+		},
+		{
+			scenario: "create snapshot",
+			request:  response,
+			update:   true,
+			expectedSnapshot: `{
+    "create_time": {
+        "seconds": 1700000000
+    },
+    "id": 42,
+    "name": "Item #42"
+}
+`,
+		},
+		{
+			scenario: "update snapshot",
+			snapshot: `{"id": 41, "name": "Item #41", "create_time": "<ignore-diff>"}`,
+			request:  response,
+			update:   true,
+			expectedSnapshot: `{
+    "create_time": "<ignore-diff>",
+    "id": 42,
+    "name": "Item #42"
+}
+`,
+		},
+		{
+			scenario: "overwrite invalid snapshot",
+			snapshot: `not json`,
+			request: func() ([]byte, error) {
+				return []byte(`[]`), nil
+			},
+			update:           true,
+			expectedSnapshot: "[]\n",
+		},
+		{
+			scenario: "request error",
+			snapshot: `{}`,
+			request: func() ([]byte, error) {
+				return nil, status.Error(codes.Internal, "internal server error")
+			},
+			update:           true,
+			expectedSnapshot: `{}`,
+			expectedError:    `an error occurred while send grpc request: rpc error: code = Internal desc = internal server error`,
+		},
+		{
+			scenario: "stream error",
+			snapshot: `[]`,
+			request: func() ([]byte, error) {
+				return []byte(`[{"id": 42}]`), status.Error(codes.Internal, "internal server error")
+			},
+			update:           true,
+			expectedSnapshot: `[]`,
+			expectedError:    `the stream ended with an error, the snapshot is not updated: rpc error: code = Internal desc = internal server error`,
+		},
+		{
+			scenario: "large numbers",
+			snapshot: `{"num_items": 1}`,
+			request: func() ([]byte, error) {
+				return []byte(`{"num_items": 9007199254740993}`), nil
+			},
+			update: true,
+			expectedSnapshot: `{
+    "num_items": 9007199254740993
+}
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "snapshots", "get-item.json")
+
+			if tc.snapshot != "" {
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				require.NoError(t, os.WriteFile(path, []byte(tc.snapshot), 0o600))
+			}
+
+			err := assertServerResponseSnapshot(tc.request, path, tc.update)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+
+			if tc.expectedSnapshot != "" {
+				actual, err := os.ReadFile(path) // nolint: gosec
+				require.NoError(t, err)
+
+				assert.Equal(t, tc.expectedSnapshot, string(actual))
+			}
+		})
+	}
+}
+
+func TestAssertServerResponseSnapshot_NoSnapshot(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "get-item.json")
+
+	err := assertServerResponseSnapshot(clientRequestDoer(func() ([]byte, error) {
+		return []byte(`{}`), nil
+	}), path, false)
+
+	expected := fmt.Sprintf(`open %s: no such file or directory, run the tests with -grpcsteps.update or GRPCSTEPS_UPDATE=1 to create it`, path)
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.EqualError(t, err, expected)
+}
+
+func TestUpdateSnapshots(t *testing.T) {
+	t.Setenv(UpdateSnapshotsEnv, "1")
+
+	assert.True(t, updateSnapshots())
+
+	// The payload files are updated too.
+	path := filepath.Join(t.TempDir(), "response.json")
+	ctx := clientRequestToContext(context.Background(), clientRequestDoer(func() ([]byte, error) {
+		return []byte(`{"id": 42}`), nil
+	}))
+
+	err := (&Client{}).iShouldHaveResponseWithPayloadFromFile(ctx, path)
+	require.NoError(t, err)
+
+	actual, err := os.ReadFile(path) // nolint: gosec
+	require.NoError(t, err)
+
+	assert.Equal(t, "{\n    \"id\": 42\n}\n", string(actual))
+}
+
+func TestKeepIgnoredDiff(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		expected       interface{}
+		actual         interface{}
+		expectedResult interface{}
+	}{
+		{
+			scenario:       "ignored value",
+			expected:       "<ignore-diff>",
+			actual:         42.0,
+			expectedResult: "<ignore-diff>",
+		},
+		{
+			scenario:       "different value",
+			expected:       41.0,
+			actual:         42.0,
+			expectedResult: 42.0,
+		},
+		{
+			scenario:       "ignored field",
+			expected:       map[string]interface{}{"id": "<ignore-diff>", "name": "foo", "locale": "<ignore-diff>"},
+			actual:         map[string]interface{}{"id": 42.0, "name": "bar"},
+			expectedResult: map[string]interface{}{"id": "<ignore-diff>", "name": "bar"},
+		},
+		{
+			scenario:       "ignored item",
+			expected:       []interface{}{map[string]interface{}{"id": "<ignore-diff>"}},
+			actual:         []interface{}{map[string]interface{}{"id": 1.0}, map[string]interface{}{"id": 2.0}},
+			expectedResult: []interface{}{map[string]interface{}{"id": "<ignore-diff>"}, map[string]interface{}{"id": 2.0}},
+		},
+		{
+			scenario:       "different types",
+			expected:       []interface{}{"<ignore-diff>"},
+			actual:         map[string]interface{}{"id": 42.0},
+			expectedResult: map[string]interface{}{"id": 42.0},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expectedResult, keepIgnoredDiff(tc.expected, tc.actual))
+		})
+	}
+}
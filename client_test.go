@@ -5,12 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
@@ -32,6 +40,236 @@ func TestClient_NoServer(t *testing.T) {
 	runClientSuite(t, c, "features/client/NoServer.feature")
 }
 
+func TestClient_Ready(t *testing.T) {
+	t.Parallel()
+
+	runClientTest(t, "Ready", testSrv.GetItem(getItem))
+}
+
+func TestClient_ReadyInMemory(t *testing.T) {
+	t.Parallel()
+
+	runClientTestInMemory(t, "Ready", testSrv.GetItem(getItem))
+}
+
+func TestClient_WaitForReady(t *testing.T) {
+	t.Parallel()
+
+	srv := grpcsteps.ServeInMemory(testSrv.NewServer(testSrv.GetItem(getItem)))
+
+	t.Cleanup(srv.Close)
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer, grpcsteps.WithInMemoryServer(srv)),
+		grpcsteps.WithWaitForReady(time.Second),
+	)
+
+	runSuite(t,
+		initSuite(c.RegisterSuiteContext),
+		initScenario(c.RegisterContext),
+		featureFiles("features/client/Ready.feature"),
+	)
+}
+
+func TestClient_WaitForReady_NotReady(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := l.Addr().String()
+
+	require.NoError(t, l.Close())
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer,
+			grpcsteps.WithAddr(addr),
+			grpcsteps.WithDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		),
+		grpcsteps.WithWaitForReady(100*time.Millisecond),
+	)
+
+	tt := &testT{}
+
+	runSuite(tt,
+		noColors(),
+		initSuite(c.RegisterSuiteContext),
+		initScenario(c.RegisterContext),
+		featureFiles("features/client/Ready.feature"),
+	)
+
+	require.Error(t, tt.error)
+	assert.Contains(t, tt.error.Error(), fmt.Sprintf(`grpc service is not ready within 100ms, address %q`, addr))
+}
+
+func TestClient_ShareConnection(t *testing.T) {
+	t.Parallel()
+
+	var dials int64
+
+	dial := testSrv.StartServer(t, testSrv.GetItem(getItem))
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer,
+			grpcsteps.WithDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+			grpcsteps.WithDialOption(grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				atomic.AddInt64(&dials, 1)
+
+				return dial(ctx, addr)
+			})),
+		),
+	)
+
+	runSuite(t,
+		concurrency(2),
+		initSuite(c.RegisterSuiteContext),
+		initScenario(c.RegisterContext),
+		featureFiles("features/client/Connection.feature"),
+	)
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&dials))
+	assert.NoError(t, c.Close())
+}
+
+func TestClient_Interceptors(t *testing.T) {
+	t.Parallel()
+
+	locale := func(ctx context.Context) string {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		return md.Get("locale")[0]
+	}
+
+	dial := testSrv.StartServer(t,
+		testSrv.GetItem(func(ctx context.Context, req *grpctest.GetItemRequest) (*grpctest.Item, error) {
+			return &grpctest.Item{Id: req.GetId(), Locale: locale(ctx), Name: fmt.Sprintf("Item #%d", req.GetId())}, nil
+		}),
+		testSrv.ListItems(func(_ *grpctest.ListItemsRequest, srv grpctest.ItemService_ListItemsServer) error {
+			return srv.Send(&grpctest.Item{Id: 42, Locale: locale(srv.Context()), Name: "Item #42"})
+		}),
+	)
+
+	h := &scenarioStatsHandler{}
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer,
+			grpcsteps.WithDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+			grpcsteps.WithDialOption(grpc.WithContextDialer(dial)),
+		),
+		// The scenarios are named after the locale.
+		grpcsteps.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			ctx = metadata.AppendToOutgoingContext(ctx, "locale", grpcsteps.ScenarioFromContext(ctx).Name)
+
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+		grpcsteps.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			ctx = metadata.AppendToOutgoingContext(ctx, "locale", grpcsteps.ScenarioFromContext(ctx).Name)
+
+			return streamer(ctx, desc, cc, method, opts...)
+		}),
+		grpcsteps.WithStatsHandler(h),
+	)
+
+	runSuite(t,
+		initSuite(c.RegisterSuiteContext),
+		initScenario(c.RegisterContext),
+		featureFiles("features/client/Interceptor.feature"),
+	)
+
+	assert.ElementsMatch(t, []string{"en-US", "fr-FR"}, h.scenarios())
+}
+
+func TestClient_ScenarioHeader(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		options  []grpcsteps.ServiceOption
+		expected bool
+	}{
+		{
+			scenario: "no header by default",
+		},
+		{
+			scenario: "with scenario header",
+			options:  []grpcsteps.ServiceOption{grpcsteps.WithScenarioHeader()},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				headers [][]string
+				mu      sync.Mutex
+			)
+
+			dial := testSrv.StartServer(t, testSrv.GetItem(func(ctx context.Context, req *grpctest.GetItemRequest) (*grpctest.Item, error) {
+				md, _ := metadata.FromIncomingContext(ctx)
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				headers = append(headers, md.Get(grpcsteps.ScenarioHeader))
+
+				return getItem(ctx, req)
+			}))
+
+			c := grpcsteps.NewClient(
+				grpcsteps.RegisterService(grpctest.RegisterItemServiceServer, append([]grpcsteps.ServiceOption{
+					grpcsteps.WithDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+					grpcsteps.WithDialOption(grpc.WithContextDialer(dial)),
+				}, tc.options...)...),
+			)
+
+			runClientSuite(t, c, "features/client/Ready.feature")
+
+			require.Len(t, headers, 1)
+			assert.Equal(t, tc.expected, len(headers[0]) == 1)
+		})
+	}
+}
+
+func TestClient_Compression(t *testing.T) {
+	t.Parallel()
+
+	// The name of the item is the compressor of the request.
+	dial := testSrv.StartServer(t, testSrv.GetItem(func(ctx context.Context, req *grpctest.GetItemRequest) (*grpctest.Item, error) {
+		s := grpc.ServerTransportStreamFromContext(ctx).(interface{ RecvCompress() string }) // nolint: errcheck
+
+		return &grpctest.Item{Id: req.GetId(), Name: s.RecvCompress()}, nil
+	}))
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer,
+			grpcsteps.WithDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+			grpcsteps.WithDialOption(grpc.WithContextDialer(dial)),
+			grpcsteps.WithCompression("gzip"),
+		),
+	)
+
+	runClientSuite(t, c, "features/client/Compression.feature")
+}
+
+func TestClient_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	srv := grpcsteps.ServeInMemory(testSrv.NewServer(testSrv.GetItem(getItem)))
+
+	t.Cleanup(srv.Close)
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer, grpcsteps.WithInMemoryServer(srv)),
+		grpcsteps.WithSnapshotDir("resources/fixtures/snapshots"),
+	)
+
+	runClientSuite(t, c, "features/client/Snapshot.feature")
+}
+
 func TestClient_GetItem(t *testing.T) {
 	t.Parallel()
 
@@ -69,6 +307,16 @@ func TestClient_GetItem(t *testing.T) {
 				return nil, status.Errorf(codes.FailedPrecondition, `invalid "id"`)
 			},
 		},
+		{
+			scenario: "FlexibleError",
+			handler: func(ctx context.Context, request *grpctest.GetItemRequest) (*grpctest.Item, error) {
+				if request.GetId() == 42 {
+					return &grpctest.Item{Id: 42, Name: "Item #42"}, nil
+				}
+
+				return nil, status.Errorf(codes.Unavailable, "upstream: request %d-%d failed: connection refused", request.GetId(), time.Now().UnixNano())
+			},
+		},
 		{
 			scenario: "Success",
 			handler: func(ctx context.Context, request *grpctest.GetItemRequest) (*grpctest.Item, error) {
@@ -155,6 +403,30 @@ func TestClient_ListItems(t *testing.T) {
 				return srv.Send(item)
 			},
 		},
+		{
+			scenario: "Stream",
+			handler: func(_ *grpctest.ListItemsRequest, srv grpctest.ItemService_ListItemsServer) error {
+				for i := int32(1); i <= 3; i++ {
+					if err := srv.Send(&grpctest.Item{Id: i, Locale: "en-US", Name: fmt.Sprintf("Item #%d", i)}); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			scenario: "PartialStream",
+			handler: func(_ *grpctest.ListItemsRequest, srv grpctest.ItemService_ListItemsServer) error {
+				for i := int32(1); i <= 2; i++ {
+					if err := srv.Send(&grpctest.Item{Id: i, Name: fmt.Sprintf("Item #%d", i)}); err != nil {
+						return err
+					}
+				}
+
+				return status.Error(codes.Aborted, "quota exceeded")
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -293,6 +565,21 @@ func TestClient_TransformItems(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			scenario: "PartialStream",
+			handler: func(srv grpctest.ItemService_TransformItemsServer) error {
+				item, err := srv.Recv()
+				if err != nil {
+					return err
+				}
+
+				if err := srv.Send(item); err != nil {
+					return err
+				}
+
+				return status.Error(codes.Aborted, "quota exceeded")
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -308,6 +595,10 @@ func TestClient_TransformItems(t *testing.T) {
 	}
 }
 
+func getItem(_ context.Context, req *grpctest.GetItemRequest) (*grpctest.Item, error) {
+	return &grpctest.Item{Id: req.GetId(), Name: fmt.Sprintf("Item #%d", req.GetId())}, nil
+}
+
 func runClientTest(t *testing.T, scenario string, opts ...testSrv.ServiceOption) {
 	t.Helper()
 
@@ -326,9 +617,55 @@ func runClientTest(t *testing.T, scenario string, opts ...testSrv.ServiceOption)
 	runClientSuite(t, c, fmt.Sprintf("features/client/%s.feature", scenario))
 }
 
+func runClientTestInMemory(t *testing.T, scenario string, opts ...testSrv.ServiceOption) {
+	t.Helper()
+
+	srv := grpcsteps.ServeInMemory(testSrv.NewServer(opts...))
+
+	t.Cleanup(srv.Close)
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer, grpcsteps.WithInMemoryServer(srv)),
+	)
+
+	runClientSuite(t, c, fmt.Sprintf("features/client/%s.feature", scenario))
+}
+
 func runClientSuite(t suiteT, c *grpcsteps.Client, paths ...string) {
 	runSuite(t,
 		initScenario(c.RegisterContext),
 		featureFiles(paths...),
 	)
 }
+
+type scenarioStatsHandler struct {
+	names []string
+
+	mu sync.Mutex
+}
+
+func (h *scenarioStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if sc := grpcsteps.ScenarioFromContext(ctx); sc != nil {
+		h.names = append(h.names, sc.Name)
+	}
+
+	return ctx
+}
+
+func (h *scenarioStatsHandler) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (h *scenarioStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *scenarioStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+func (h *scenarioStatsHandler) scenarios() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.names
+}
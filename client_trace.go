@@ -0,0 +1,244 @@
+package grpcsteps
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tracerName is the name of the tracer of the client requests.
+const tracerName = "github.com/godogx/grpcsteps"
+
+// traceContext propagates the trace of the requests with the W3C traceparent and tracestate headers.
+var traceContext = propagation.TraceContext{}
+
+// clientTracing starts a span per request and keeps the ended spans in memory.
+type clientTracing struct {
+	provider *sdktrace.TracerProvider
+	spans    *tracetest.SpanRecorder
+}
+
+// Start starts the span of a request and injects its trace context in the header. The tracing could be nil if it is not
+// enabled.
+func (t *clientTracing) Start(ctx context.Context, method string, header metadata.MD) (context.Context, trace.Span) {
+	if t == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	svc, name := splitMethod(method)
+
+	ctx, span := t.provider.Tracer(tracerName).Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", svc),
+			attribute.String("rpc.method", name),
+		),
+	)
+
+	traceContext.Inject(ctx, metadataCarrier(header))
+
+	return ctx, span
+}
+
+// End ends the span of a request with its status.
+func (t *clientTracing) End(span trace.Span, err error) {
+	if t == nil {
+		return
+	}
+
+	st := status.Convert(err)
+
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(st.Code())))
+
+	if err != nil {
+		span.SetStatus(otelcodes.Error, st.Message())
+	}
+
+	span.End()
+}
+
+// Trace returns the ended spans of the trace, ordered by their start time.
+func (t *clientTracing) Trace(id trace.TraceID) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+
+	for _, s := range t.spans.Ended() {
+		if s.SpanContext().TraceID() == id {
+			spans = append(spans, s)
+		}
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].StartTime().Before(spans[j].StartTime())
+	})
+
+	return spans
+}
+
+func newClientTracing() *clientTracing {
+	spans := tracetest.NewSpanRecorder()
+
+	return &clientTracing{
+		provider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+		spans:    spans,
+	}
+}
+
+// metadataCarrier carries the trace context in the grpc metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// headerSpanContext returns the span context that the header carries, if any.
+func headerSpanContext(header metadata.MD) trace.SpanContext {
+	return trace.SpanContextFromContext(traceContext.Extract(context.Background(), metadataCarrier(header)))
+}
+
+// clientRequestSpanContext returns the span context of the client request in context.
+func clientRequestSpanContext(ctx context.Context) (trace.SpanContext, error) {
+	r, err := sentClientRequest(ctx)
+	if err != nil {
+		return trace.SpanContext{}, err
+	}
+
+	if !r.spanContext.IsValid() {
+		return trace.SpanContext{}, fmt.Errorf("%w, use grpcsteps.WithTracing() to trace the client requests", ErrTracingNotEnabled)
+	}
+
+	return r.spanContext, nil
+}
+
+// assertClientRequestSpans compares the spans of the trace of the client request with a tree of span names, a child is
+// indented under its parent.
+func assertClientRequestSpans(ctx context.Context, t *clientTracing, expected string) error {
+	sc, err := clientRequestSpanContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	expected = normalizeSpanTree(expected)
+	actual := spanTree(t.Trace(sc.TraceID()))
+
+	if expected != actual {
+		return fmt.Errorf("%w in trace %s, expected:\n%s\nactual:\n%s", ErrUnexpectedSpans, sc.TraceID(), expected, actual)
+	}
+
+	return nil
+}
+
+// assertClientRequestSpan checks that the trace of the client request has a span.
+func assertClientRequestSpan(ctx context.Context, t *clientTracing, name string) error {
+	sc, err := clientRequestSpanContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	spans := t.Trace(sc.TraceID())
+
+	for _, s := range spans {
+		if s.Name() == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w in trace %s, no span %q in:\n%s", ErrUnexpectedSpans, sc.TraceID(), name, spanTree(spans))
+}
+
+// spanTree formats the spans as a tree of names, the children are indented with 4 spaces under their parent.
+func spanTree(spans []sdktrace.ReadOnlySpan) string {
+	ids := make(map[trace.SpanID]bool, len(spans))
+	children := make(map[trace.SpanID][]sdktrace.ReadOnlySpan, len(spans))
+
+	for _, s := range spans {
+		ids[s.SpanContext().SpanID()] = true
+	}
+
+	var roots []sdktrace.ReadOnlySpan
+
+	for _, s := range spans {
+		if parent := s.Parent().SpanID(); ids[parent] {
+			children[parent] = append(children[parent], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	var sb strings.Builder
+
+	var write func(spans []sdktrace.ReadOnlySpan, depth int)
+
+	write = func(spans []sdktrace.ReadOnlySpan, depth int) {
+		for _, s := range spans {
+			_, _ = fmt.Fprintf(&sb, "%s%s\n", strings.Repeat("    ", depth), s.Name())
+
+			write(children[s.SpanContext().SpanID()], depth+1)
+		}
+	}
+
+	write(roots, 0)
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// normalizeSpanTree indents a tree of span names like spanTree. The tabs are 4 spaces, the blank lines and the common
+// indentation are removed.
+func normalizeSpanTree(tree string) string {
+	lines := make([]string, 0)
+	indent := -1
+
+	for _, line := range strings.Split(strings.ReplaceAll(tree, "\t", "    "), "\n") {
+		line = strings.TrimRight(line, " ")
+		if line == "" {
+			continue
+		}
+
+		if n := len(line) - len(strings.TrimLeft(line, " ")); indent < 0 || n < indent {
+			indent = n
+		}
+
+		lines = append(lines, line)
+	}
+
+	for i, line := range lines {
+		lines[i] = line[indent:]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// splitMethod splits a full method name, like /grpctest.ItemService/GetItem, into its service and method.
+func splitMethod(method string) (string, string) {
+	svc, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+
+	return svc, name
+}
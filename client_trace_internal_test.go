@@ -0,0 +1,183 @@
+package grpcsteps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientTracing(t *testing.T) {
+	t.Parallel()
+
+	tracing := newClientTracing()
+	header := metadata.MD{}
+
+	_, span := tracing.Start(context.Background(), "/grpctest.ItemService/GetItem", header)
+	sc := span.SpanContext()
+
+	tracing.End(span, status.Error(codes.NotFound, "item not found"))
+
+	// The trace context is in the header.
+	require.Len(t, header.Get("traceparent"), 1)
+	assert.Equal(t, sc, headerSpanContext(header).WithRemote(false))
+
+	spans := tracing.Trace(sc.TraceID())
+
+	require.Len(t, spans, 1)
+	assert.Equal(t, "/grpctest.ItemService/GetItem", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Equal(t, otelcodes.Error, spans[0].Status().Code)
+	assert.Equal(t, "item not found", spans[0].Status().Description)
+
+	attrs := make(map[string]interface{})
+
+	for _, a := range spans[0].Attributes() {
+		attrs[string(a.Key)] = a.Value.AsInterface()
+	}
+
+	expected := map[string]interface{}{
+		"rpc.system":           "grpc",
+		"rpc.service":          "grpctest.ItemService",
+		"rpc.method":           "GetItem",
+		"rpc.grpc.status_code": int64(codes.NotFound),
+	}
+
+	assert.Equal(t, expected, attrs)
+}
+
+func TestClientTracing_NotEnabled(t *testing.T) {
+	t.Parallel()
+
+	var tracing *clientTracing
+
+	header := metadata.MD{}
+
+	_, span := tracing.Start(context.Background(), "/grpctest.ItemService/GetItem", header)
+
+	tracing.End(span, nil)
+
+	assert.False(t, span.SpanContext().IsValid())
+	assert.Empty(t, header)
+}
+
+func TestClientRequestSpans(t *testing.T) {
+	t.Parallel()
+
+	tracing := newClientTracing()
+	tracer := tracing.provider.Tracer("test")
+
+	req := &clientRequestInvoker{}
+
+	ctx, root := tracer.Start(context.Background(), "/grpctest.ItemService/GetItem")
+	req.spanContext = root.SpanContext()
+
+	ctx1, span := tracer.Start(ctx, "find item")
+	_, child := tracer.Start(ctx1, "query")
+	child.End()
+	span.End()
+
+	_, span = tracer.Start(ctx, "cache item")
+	span.End()
+	root.End()
+
+	// Another trace.
+	_, span = tracer.Start(context.Background(), "other")
+	span.End()
+
+	req.once.Do(func() {})
+
+	ctx = clientRequestToContext(context.Background(), req)
+
+	testCases := []struct {
+		scenario      string
+		tree          string
+		expectedError string
+	}{
+		{
+			scenario: "same tree",
+			tree: `
+				/grpctest.ItemService/GetItem
+				    find item
+				        query
+				    cache item
+			`,
+		},
+		{
+			scenario: "different tree",
+			tree: `
+/grpctest.ItemService/GetItem
+    find item
+    query
+    cache item
+`,
+			expectedError: `unexpected spans in trace ` + root.SpanContext().TraceID().String() + `, expected:
+/grpctest.ItemService/GetItem
+    find item
+    query
+    cache item
+actual:
+/grpctest.ItemService/GetItem
+    find item
+        query
+    cache item`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := assertClientRequestSpans(ctx, tracing, tc.tree)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+
+	assert.NoError(t, assertClientRequestSpan(ctx, tracing, "query"))
+
+	expected := `unexpected spans in trace ` + root.SpanContext().TraceID().String() + `, no span "other" in:
+/grpctest.ItemService/GetItem
+    find item
+        query
+    cache item`
+
+	assert.EqualError(t, assertClientRequestSpan(ctx, tracing, "other"), expected)
+}
+
+func TestClientRequestSpanContext(t *testing.T) {
+	t.Parallel()
+
+	// No request.
+	_, err := clientRequestSpanContext(context.Background())
+
+	assert.ErrorIs(t, err, ErrNoClientRequestInContext)
+
+	// No tracing.
+	req := &clientRequestInvoker{}
+	req.once.Do(func() {})
+
+	_, err = clientRequestSpanContext(clientRequestToContext(context.Background(), req))
+
+	assert.EqualError(t, err, `tracing is not enabled, use grpcsteps.WithTracing() to trace the client requests`)
+}
+
+func TestSplitMethod(t *testing.T) {
+	t.Parallel()
+
+	svc, method := splitMethod("/grpctest.ItemService/GetItem")
+
+	assert.Equal(t, "grpctest.ItemService", svc)
+	assert.Equal(t, "GetItem", method)
+}
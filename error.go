@@ -5,10 +5,52 @@ const (
 	ErrInvalidGRPCMethod err = `invalid grpc method`
 	// ErrGRPCServiceNotFound indicates that the service is not found.
 	ErrGRPCServiceNotFound err = `grpc service not found`
+	// ErrGRPCServiceNotReady indicates that the service is not ready in time.
+	ErrGRPCServiceNotReady err = `grpc service is not ready`
 	// ErrGRPCMethodNotFound indicates that the service method is not found.
 	ErrGRPCMethodNotFound err = `grpc method not found`
 	// ErrGRPCMethodNotSupported indicates that the service method is not supported.
 	ErrGRPCMethodNotSupported err = `grpc method not supported`
+	// ErrInvalidResponseSequence indicates that the sequence of responses is invalid.
+	ErrInvalidResponseSequence err = `invalid response sequence`
+	// ErrInvalidPercentage indicates that the percentage is not between 0 and 100.
+	ErrInvalidPercentage err = `invalid percentage`
+	// ErrInvalidLatency indicates that the latency range is invalid.
+	ErrInvalidLatency err = `invalid latency`
+	// ErrInvalidHealthStatus indicates that the health status is not a grpc.health.v1 serving status.
+	ErrInvalidHealthStatus err = `invalid health status`
+	// ErrHealthServiceNotEnabled indicates that the mocked service does not serve the health service.
+	ErrHealthServiceNotEnabled err = `health service is not enabled`
+	// ErrUnknownCompressor indicates that the compressor is not registered.
+	ErrUnknownCompressor err = `unknown compressor`
+	// ErrInvalidMessageSize indicates that the message size is not a number of bytes.
+	ErrInvalidMessageSize err = `invalid message size`
+	// ErrInvalidTable indicates that the table is not a payload.
+	ErrInvalidTable err = `invalid table`
+	// ErrInvalidFieldPath indicates that the field path of a table is invalid.
+	ErrInvalidFieldPath err = `invalid field path`
+	// ErrUnknownField indicates that the message does not have the field of a table.
+	ErrUnknownField err = `unknown field`
+	// ErrInvalidFieldValue indicates that the value of a table does not fit the type of the field.
+	ErrInvalidFieldValue err = `invalid field value`
+	// ErrInvalidHeader indicates that the header of a table is invalid.
+	ErrInvalidHeader err = `invalid header`
+	// ErrNotStreamResponse indicates that the response of the method is not a stream.
+	ErrNotStreamResponse err = `grpc response is not a stream`
+	// ErrInvalidPredicate indicates that the predicate is not a JSONPath expression.
+	ErrInvalidPredicate err = `invalid predicate`
+	// ErrTracingNotEnabled indicates that the client does not trace the requests.
+	ErrTracingNotEnabled err = `tracing is not enabled`
+	// ErrUnexpectedSpans indicates that the trace of the request does not have the expected spans.
+	ErrUnexpectedSpans err = `unexpected spans`
+	// ErrTraceNotPropagated indicates that the mocked service did not receive a request in the trace of the client request.
+	ErrTraceNotPropagated err = `trace not propagated`
+	// ErrHeaderNotPropagated indicates that the mocked service did not receive the header of the client request.
+	ErrHeaderNotPropagated err = `header not propagated`
+	// ErrInvalidDeadline indicates that the remaining deadline is not a positive duration.
+	ErrInvalidDeadline err = `invalid deadline`
+	// ErrDeadlineNotPropagated indicates that the mocked service did not receive a request with the expected deadline.
+	ErrDeadlineNotPropagated err = `deadline not propagated`
 )
 
 type err string
@@ -0,0 +1,34 @@
+Feature: Compress the requests
+
+    Scenario: Compress with the compressor of the service
+        When I request a gRPC method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a gRPC response with payload:
+        """
+        {
+            "id": 42,
+            "name": "gzip"
+        }
+        """
+
+    Scenario: Override the compressor of the service
+        When I request a gRPC method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the gRPC request uses compression "identity"
+
+        Then I should have a gRPC response with payload:
+        """
+        {
+            "id": 42,
+            "name": "identity"
+        }
+        """
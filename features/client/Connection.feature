@@ -0,0 +1,27 @@
+Feature: Share the connection
+
+    Scenario: Wait for the server
+        Given the gRPC service "grpctest.ItemService" is ready within "1s"
+
+    Scenario Outline: Get item <id>
+        When I request a gRPC method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": <id>
+        }
+        """
+
+        Then I should have a gRPC response with payload:
+        """
+        {
+            "id": <id>,
+            "name": "Item #<id>"
+        }
+        """
+
+        Examples:
+            | id |
+            | 1  |
+            | 2  |
+            | 3  |
+            | 4  |
@@ -0,0 +1,43 @@
+Feature: Assert an error that is not always the same
+
+    Scenario: Error message contains a text
+        When I request a gRPC method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 41
+        }
+        """
+
+        Then the gRPC response code should be one of "Unavailable, DeadlineExceeded"
+        And the gRPC error message should contain "connection refused"
+        And the gRPC response error message should contain:
+        """
+        upstream: request 41-
+        """
+
+    Scenario: Error message matches a regexp
+        When I request a gRPC method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 41
+        }
+        """
+
+        Then I should have a gRPC response with code 14
+        And I should have a gRPC response with code "14"
+        And the gRPC error message should match regexp "^upstream: request 41-[0-9]+ failed"
+        And the gRPC error message should match regexp:
+        """
+        connection refused$
+        """
+
+    Scenario: No error
+        When I request a gRPC method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should not have a gRPC error
+        And the gRPC response code should be one of "OK"
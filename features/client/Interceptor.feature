@@ -0,0 +1,35 @@
+Feature: Intercept the requests
+
+    Scenario: en-US
+        When I request a gRPC method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a gRPC response with payload:
+        """
+        {
+            "id": 42,
+            "locale": "en-US",
+            "name": "Item #42"
+        }
+        """
+
+    Scenario: fr-FR
+        When I request a gRPC method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then I should have a gRPC response with payload:
+        """
+        [
+            {
+                "id": 42,
+                "locale": "fr-FR",
+                "name": "Item #42"
+            }
+        ]
+        """
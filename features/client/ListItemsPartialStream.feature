@@ -0,0 +1,41 @@
+Feature: List items until the stream fails
+
+    Scenario: Assert the messages and the error
+        When I request a gRPC method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then I should have a gRPC response with payload:
+        """
+        [
+            {
+                "id": 1,
+                "name": "Item #1"
+            },
+            {
+                "id": 2,
+                "name": "Item #2"
+            }
+        ]
+        """
+
+        And I should have a gRPC response with code "Aborted" and error "quota exceeded"
+
+    Scenario: Assert the messages one by one
+        When I request a gRPC method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then I should have 2 gRPC stream messages
+
+        And gRPC stream message 2 should have payload:
+        """
+        {
+            "id": 2,
+            "name": "Item #2"
+        }
+        """
+
+        And I should have a gRPC response with code "Aborted"
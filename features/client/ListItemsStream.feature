@@ -0,0 +1,68 @@
+Feature: Assert the messages of a stream
+
+    Scenario: Count the messages
+        When I request a gRPC method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then I should have 3 gRPC stream messages
+
+    Scenario: Assert a message
+        When I request a gRPC method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then gRPC stream message 1 should have payload:
+        """
+        {
+            "id": 1,
+            "locale": "en-US",
+            "name": "Item #1"
+        }
+        """
+
+        And the gRPC stream message 3 should have payload:
+        """
+        {
+            "id": 3,
+            "locale": "<ignore-diff>",
+            "name": "Item #3"
+        }
+        """
+
+    Scenario: Contain a message in any order
+        When I request a gRPC method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then the gRPC stream should contain message:
+        """
+        {
+            "id": 2,
+            "locale": "en-US",
+            "name": "Item #2"
+        }
+        """
+
+        And the gRPC stream should contain a message:
+        """
+        {
+            "id": 1,
+            "locale": "<ignore-diff>",
+            "name": "<ignore-diff>"
+        }
+        """
+
+    Scenario: Match all the messages
+        When I request a gRPC method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then all gRPC stream messages should match:
+            | $.id > 0 && $.id <= 3     |
+            | $.locale == "en-US"       |
+            | $.name =~ "^Item #[0-9]$" |
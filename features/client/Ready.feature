@@ -0,0 +1,19 @@
+Feature: Server is ready
+
+    Scenario: Wait for the server
+        Given the gRPC service "grpctest.ItemService" is ready within "1s"
+
+        When I request a gRPC method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a gRPC response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
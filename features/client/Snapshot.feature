@@ -0,0 +1,11 @@
+Feature: Compare the response with a snapshot
+
+    Scenario: Get item
+        When I request a gRPC method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a gRPC response matching snapshot "get-item.json"
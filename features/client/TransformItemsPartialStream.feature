@@ -0,0 +1,28 @@
+Feature: Transform items until the stream fails
+
+    Scenario: Assert the messages and the error
+        When I request a gRPC method "/grpctest.ItemService/TransformItems" with payload:
+        """
+        [
+            {
+                "id": 1,
+                "name": "Item #1"
+            },
+            {
+                "id": 2,
+                "name": "Item #2"
+            }
+        ]
+        """
+
+        Then I should have a gRPC response with payload:
+        """
+        [
+            {
+                "id": 1,
+                "name": "Item #1"
+            }
+        ]
+        """
+
+        And I should have a gRPC response with code "Aborted" and error "quota exceeded"
@@ -0,0 +1,82 @@
+Feature: Compression and call options
+
+    Scenario Outline: Request is compressed with <compression>
+        Given "item-service" receives a grpc request "/grpctest.ItemService/<method>" with payload:
+        """
+        <request>
+        """
+        And the grpc request is compressed with "<compression>"
+        And the grpc service responds with payload:
+        """
+        <response>
+        """
+
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload:
+        """
+        <request>
+        """
+        And the grpc request uses compression "<compression>"
+
+        Then I should have a grpc response with payload:
+        """
+        <response>
+        """
+
+        Examples:
+            | compression | method      | request      | response                   |
+            | gzip        | GetItem     | {"id": 42}   | {"id": 42, "name": "Item"} |
+            | gzip        | ListItems   | {}           | [{"id": 42}]               |
+            | gzip        | CreateItems | [{"id": 42}] | {"num_items": 1}           |
+            | deflate     | GetItem     | {"id": 42}   | {"id": 42, "name": "Item"} |
+
+    Scenario: Request is not compressed as expected
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem"
+        And the grpc request is compressed with "gzip"
+        And the grpc service responds with code "InvalidArgument"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id":42}
+        """
+
+        Then I should have a grpc response with code "Internal" and error:
+        """
+        Expected: Unary /grpctest.ItemService/GetItem
+        Actual: Unary /grpctest.ItemService/GetItem
+            with payload
+                {"id":42}
+        Error: compression "gzip" expected, "" received
+
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id":42}
+        """
+        And the grpc request uses compression "gzip"
+
+        Then I should have a grpc response with code "InvalidArgument"
+
+    Scenario: Response is larger than the max receive message size
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem"
+        And the grpc service responds with payload:
+        """
+        {"id": 42, "name": "A name that is longer than 16 bytes"}
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id": 42}
+        """
+        And the grpc request max receive message size is "16B"
+
+        Then I should have a grpc response with code "ResourceExhausted"
+
+    Scenario: Request is larger than the max send message size
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id": 42}
+        """
+        And the grpc request max send message size is "1"
+
+        Then I should have a grpc response with code "ResourceExhausted"
@@ -0,0 +1,48 @@
+Feature: Scenarios run concurrently
+
+    Scenario Outline: Same request receives the response of its own scenario
+        Given "item-service" adds random latency between "10ms" and "50ms"
+        And "item-service" is in state "<state>"
+
+        And "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc request is expected when in state "<state>"
+        And the grpc service moves to state "done"
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "<name>"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "<name>"
+        }
+        """
+        And "item-service" should be in state "done"
+
+        Examples:
+            | state   | name    |
+            | first   | Item #1 |
+            | second  | Item #2 |
+            | third   | Item #3 |
+            | fourth  | Item #4 |
+            | fifth   | Item #5 |
+            | sixth   | Item #6 |
+            | seventh | Item #7 |
+            | eighth  | Item #8 |
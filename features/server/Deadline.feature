@@ -0,0 +1,57 @@
+Feature: Deadline propagation
+
+    Scenario: The mocked service receives the default deadline of the client request
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem"
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+        And "item-service" should receive a grpc request with a deadline
+        And "item-service" should have received a grpc request with remaining deadline less than "2s"
+
+    Scenario: The mocked service receives the timeout of the client request
+        Given "item-service" receives a grpc request "/grpctest.ItemService/ListItems"
+        And the grpc service responds with payload:
+        """
+        [
+            {
+                "id": 42,
+                "name": "Item #42"
+            }
+        ]
+        """
+
+        When I request a grpc method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+        And the grpc request timeout is "300ms"
+
+        Then I should have a grpc response with payload:
+        """
+        [
+            {
+                "id": 42,
+                "name": "Item #42"
+            }
+        ]
+        """
+        And "item-service" should receive a grpc request with remaining deadline less than "500ms"
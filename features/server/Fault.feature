@@ -0,0 +1,107 @@
+Feature: Inject faults
+
+    Scenario Outline: Drop the connection
+        Given "item-service" receives a grpc request "/grpctest.ItemService/<method>"
+        And the grpc service responds with code "InvalidArgument"
+
+        And "item-service" drops the connection on the next grpc request
+
+        # 1st attempt.
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload:
+        """
+        <request>
+        """
+
+        Then I should have a grpc response with code "Unavailable"
+
+        # 2nd attempt.
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload:
+        """
+        <request>
+        """
+
+        Then I should have a grpc response with code "InvalidArgument"
+
+        Examples:
+            | method      | request     |
+            | GetItem     | {"id":42}   |
+            | ListItems   | {}          |
+            | CreateItems | [{"id":42}] |
+
+    Scenario: Drop the connection several times
+        Given "item-service" drops the connection on the next 2 grpc requests
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id": 42}
+        """
+
+        Then I should have a grpc response with code "Unavailable"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id": 42}
+        """
+
+        Then I should have a grpc response with code "Unavailable"
+
+    Scenario Outline: Fail all the requests
+        Given "item-service" fails 100% of grpc requests with code "Unavailable"
+
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload:
+        """
+        <request>
+        """
+
+        Then I should have a grpc response with code "Unavailable" and error "fault injected"
+
+        Examples:
+            | method      | request     |
+            | GetItem     | {"id":42}   |
+            | ListItems   | {}          |
+            | CreateItems | [{"id":42}] |
+
+    Scenario: Fail no request
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem"
+        And the grpc service responds with code "InvalidArgument"
+
+        And "item-service" fails 0% of grpc requests with code "Unavailable"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id": 42}
+        """
+
+        Then I should have a grpc response with code "InvalidArgument"
+
+    Scenario: Add latency
+        Given "item-service" receives some grpc requests "/grpctest.ItemService/GetItem"
+        And the grpc service responds with code "InvalidArgument"
+
+        And "item-service" adds random latency between "100ms" and "200ms"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id": 42}
+        """
+        And the grpc request timeout is "50ms"
+
+        Then I should have a grpc response with code "DeadlineExceeded"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id": 42}
+        """
+
+        Then I should have a grpc response with code "InvalidArgument"
+
+    Scenario: Faults are reset after each scenario
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem"
+        And the grpc service responds with code "InvalidArgument"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id": 42}
+        """
+
+        Then I should have a grpc response with code "InvalidArgument"
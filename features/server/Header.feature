@@ -0,0 +1,117 @@
+Feature: Headers from tables
+
+    Scenario Outline: With the same headers
+        Given "item-service" receives a grpc request "/grpctest.ItemService/<method>"
+        And the grpc request has headers:
+            | Locale    | en-US |
+            | Tag       | foo   |
+            | Tag       | bar   |
+            | Trace-Bin | AQID  |
+        And the grpc service responds with code "InvalidArgument"
+
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload:
+        """
+        <request>
+        """
+        And the grpc request has headers:
+            | Locale    | en-US |
+            | Tag       | foo   |
+            | Tag       | bar   |
+            | Trace-Bin | AQID  |
+
+        Then I should have a grpc response with code "InvalidArgument"
+
+        Examples:
+            | method      | request     |
+            | GetItem     | {"id":42}   |
+            | ListItems   | {}          |
+            | CreateItems | [{"id":42}] |
+
+    Scenario Outline: With different headers
+        Given "item-service" receives a grpc request "/grpctest.ItemService/<method>"
+        And the grpc request has headers:
+            | Tag | foo |
+            | Tag | bar |
+        And the grpc service responds with code "InvalidArgument"
+
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload:
+        """
+        <request>
+        """
+        And the grpc request has headers:
+            | Tag | bar |
+            | Tag | foo |
+
+        Then I should have a grpc response with code "Internal" and error:
+        """
+        Expected: <type> /grpctest.ItemService/<method>
+        Actual: <type> /grpctest.ItemService/<method>
+            with payload
+                <request>
+        Error: header "tag" with values ["foo" "bar"] expected, ["bar" "foo"] received
+
+        """
+
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload:
+        """
+        <request>
+        """
+        And the grpc request has headers:
+            | Tag | foo |
+            | Tag | bar |
+
+        Then I should have a grpc response with code "InvalidArgument"
+
+        Examples:
+            | method      | type         | request     |
+            | GetItem     | Unary        | {"id":42}   |
+            | ListItems   | ServerStream | {}          |
+            | CreateItems | ClientStream | [{"id":42}] |
+
+    Scenario: Binary header without padding
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem"
+        And the grpc request has headers:
+            | trace-bin | AQIDBA== |
+        And the grpc service responds with code "InvalidArgument"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id":42}
+        """
+        And the grpc request has headers:
+            | trace-bin | AQIDBA |
+
+        Then I should have a grpc response with code "InvalidArgument"
+
+    Scenario: With more values than expected
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem"
+        And the grpc request has headers:
+            | Tag | foo |
+        And the grpc service responds with code "InvalidArgument"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id":42}
+        """
+        And the grpc request has headers:
+            | Tag | foo |
+            | Tag | bar |
+
+        Then I should have a grpc response with code "Internal" and error:
+        """
+        Expected: Unary /grpctest.ItemService/GetItem
+        Actual: Unary /grpctest.ItemService/GetItem
+            with payload
+                {"id":42}
+        Error: header "tag" with values ["foo"] expected, ["foo" "bar"] received
+
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {"id":42}
+        """
+        And the grpc request has headers:
+            | Tag | foo |
+
+        Then I should have a grpc response with code "InvalidArgument"
@@ -0,0 +1,70 @@
+Feature: Header propagation
+
+    Scenario: The mocked service receives the header of the client request
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem"
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc request has a header "X-Tenant-Id: tenant-42"
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+        And the grpc request header "x-tenant-id" should be propagated to "item-service"
+        And the grpc request header "X-Tenant-Id" should be propagated to "item-service"
+
+    Scenario: The mocked service receives the header values of every request
+        Given "item-service" receives 2 grpc requests "/grpctest.ItemService/ListItems"
+        And the grpc service responds with payload:
+        """
+        [
+            {
+                "id": 42,
+                "name": "Item #42"
+            }
+        ]
+        """
+
+        When I request a grpc method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+        And the grpc request has headers:
+            | x-tenant-id | tenant-42 |
+            | x-tenant-id | tenant-43 |
+
+        Then I should have a grpc response with payload:
+        """
+        [
+            {
+                "id": 42,
+                "name": "Item #42"
+            }
+        ]
+        """
+        And the grpc request header "x-tenant-id" should be propagated to "item-service"
+
+        When I request a grpc method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+        And the grpc request has headers:
+            | x-tenant-id | tenant-42 |
+            | x-tenant-id | tenant-43 |
+
+        Then the grpc request header "x-tenant-id" should be propagated to "item-service"
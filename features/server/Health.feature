@@ -0,0 +1,123 @@
+Feature: Health checks
+
+    Scenario: Service is serving by default
+        When I request a grpc method "/grpc.health.v1.Health/Check" with payload:
+        """
+        {}
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "status": 1
+        }
+        """
+
+    Scenario: Served service is serving by default
+        When I request a grpc method "/grpc.health.v1.Health/Check" with payload:
+        """
+        {
+            "service": "grpctest.ItemService"
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "status": 1
+        }
+        """
+
+    Scenario: Service is not serving
+        Given "item-service" health status is "NOT_SERVING"
+
+        When I request a grpc method "/grpc.health.v1.Health/Check" with payload:
+        """
+        {}
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "status": 2
+        }
+        """
+
+    Scenario: Served service is not serving
+        Given "item-service" health status for service "grpctest.ItemService" is "NOT_SERVING"
+
+        When I request a grpc method "/grpc.health.v1.Health/Check" with payload:
+        """
+        {
+            "service": "grpctest.ItemService"
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "status": 2
+        }
+        """
+
+    Scenario: Health checks do not meet the expectations
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+        And "item-service" drops the connection on the next grpc request
+
+        When I request a grpc method "/grpc.health.v1.Health/Check" with payload:
+        """
+        {}
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "status": 1
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with code "Unavailable"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+    Scenario: Unknown service
+        When I request a grpc method "/grpc.health.v1.Health/Check" with payload:
+        """
+        {
+            "service": "grpctest.UnknownService"
+        }
+        """
+
+        Then I should have a grpc response with code "NotFound" and error "unknown service"
@@ -0,0 +1,95 @@
+Feature: Record and replay the interactions with the upstream
+
+    Scenario: Unary requests
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 43
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 43,
+            "name": "Upstream Item #43"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 44
+        }
+        """
+
+        Then I should have a grpc response with code "NotFound" and error "Item #44 not found"
+
+    Scenario: Streams
+        When I request a grpc method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        [
+            {
+                "id": 40,
+                "name": "Upstream Item #40"
+            },
+            {
+                "id": 41,
+                "name": "Upstream Item #41"
+            }
+        ]
+        """
+
+        When I request a grpc method "/grpctest.ItemService/CreateItems" with payload:
+        """
+        [
+            {
+                "id": 40
+            },
+            {
+                "id": 41
+            }
+        ]
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "num_items": 2
+        }
+        """
+
+    Scenario: Expectations take precedence over the recorded interactions
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 43
+        }
+        """
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 43,
+            "name": "Item #43"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 43
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 43,
+            "name": "Item #43"
+        }
+        """
@@ -0,0 +1,48 @@
+Feature: Respond in sequence
+
+    Scenario Outline: Retry until success
+        Given "item-service" receives 3 grpc requests "/grpctest.ItemService/<method>"
+        And the grpc service responds in sequence:
+            | code        | payload         |
+            | Unavailable |                 |
+            | Unavailable | try again later |
+            | OK          | <response_file> |
+
+        # 1st attempt.
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload from file "<request_file>"
+
+        Then I should have a grpc response with code "Unavailable"
+
+        # 2nd attempt.
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload from file "<request_file>"
+
+        Then I should have a grpc response with code "Unavailable" and error "try again later"
+
+        # 3rd attempt.
+        When I request a grpc method "/grpctest.ItemService/<method>" with payload from file "<request_file>"
+
+        Then I should have a grpc response with payload from file "<response_file>"
+
+        Examples:
+            | method      | request_file                                 | response_file                                 |
+            | GetItem     | resources/fixtures/request-get-item.json     | resources/fixtures/response-get-item.json     |
+            | ListItems   | resources/fixtures/request-list-items.json   | resources/fixtures/response-list-items.json   |
+            | CreateItems | resources/fixtures/request-create-items.json | resources/fixtures/response-create-items.json |
+
+    Scenario: Repeat the last response when the sequence is exhausted
+        Given "item-service" receives some grpc requests "/grpctest.ItemService/GetItem"
+        And the grpc service responds in sequence:
+            | Internal | |
+            | NotFound | |
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload from file "resources/fixtures/request-get-item.json"
+
+        Then I should have a grpc response with code "Internal"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload from file "resources/fixtures/request-get-item.json"
+
+        Then I should have a grpc response with code "NotFound"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload from file "resources/fixtures/request-get-item.json"
+
+        Then I should have a grpc response with code "NotFound"
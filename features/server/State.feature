@@ -0,0 +1,109 @@
+Feature: Stateful services
+
+    Scenario: Service starts in the initial state
+        Then "item-service" should be in state "Started"
+
+    Scenario: Same request receives different responses depending on the state
+        Given "item-service" is in state "created"
+
+        And "item-service" receives some grpc requests "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc request is expected when in state "deleted"
+        And the grpc service responds with code "NotFound" and error "Item #42 not found"
+
+        And "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc request is expected when in state "created"
+        And the grpc service moves to state "deleted"
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+        # 1st request.
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+        And "item-service" should be in state "deleted"
+
+        # 2nd request.
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with code "NotFound" and error "Item #42 not found"
+
+    Scenario: Stateless expectations are matched when no stateful expectation matches
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc request is expected when in state "created"
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+        And "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc service moves to state "created"
+        And the grpc service responds with code "NotFound"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with code "NotFound"
+        And "item-service" should be in state "created"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
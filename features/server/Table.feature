@@ -0,0 +1,98 @@
+Feature: Payloads from tables
+
+    Scenario: Unary
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload from table:
+            | id | 42 |
+        And the grpc service responds with payload from table:
+            | id         | 42                     |
+            | name       | Item #42               |
+            | createTime | "2023-11-14T22:13:20Z" |
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload from table:
+            | id | 42 |
+
+        Then I should have a grpc response with payload from table:
+            | id                  | 42         |
+            | name                | Item #42   |
+            | create_time.seconds | 1700000000 |
+
+    Scenario: Ignore diff
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload from table:
+            | id | 42 |
+        And the grpc service responds with payload from table:
+            | id         | 42                     |
+            | name       | Item #42               |
+            | createTime | "2023-11-14T22:13:20Z" |
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload from table:
+            | id | 42 |
+
+        Then I should have a grpc response with payload from table:
+            | id          | 42            |
+            | name        | <ignore-diff> |
+            | create_time | <ignore-diff> |
+
+    Scenario: Server stream
+        Given "item-service" receives a grpc request "/grpctest.ItemService/ListItems"
+        And the grpc service responds with payload from table:
+            | id | name     | locale |
+            | 1  | Item #1  | en-US  |
+            | 2  | Item #2  |        |
+
+        When I request a grpc method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then I should have a grpc response with payload from table:
+            | id | name     | locale |
+            | 1  | Item #1  | en-US  |
+            | 2  | Item #2  |        |
+
+    Scenario: Client stream
+        Given "item-service" receives a grpc request "/grpctest.ItemService/CreateItems" with payload from table:
+            | id | name    |
+            | 1  | Item #1 |
+            | 2  | Item #2 |
+        And the grpc service responds with payload from table:
+            | num_items | 2 |
+
+        When I request a grpc method "/grpctest.ItemService/CreateItems" with payload from table:
+            | id | name    |
+            | 1  | Item #1 |
+            | 2  | Item #2 |
+
+        Then I should have a grpc response with payload from table:
+            | num_items | 2 |
+
+    Scenario: Repeated requests
+        Given "item-service" receives 2 grpc requests "/grpctest.ItemService/GetItem" with payload from table:
+            | id | 42 |
+        And the grpc service responds with payload from table:
+            | id | 42 |
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload from table:
+            | id | 42 |
+
+        Then I should have a grpc response with payload from table:
+            | id | 42 |
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload from table:
+            | id | 42 |
+
+        Then I should have a grpc response with payload from table:
+            | id | 42 |
+
+    Scenario: Many requests
+        Given "item-service" receives many grpc requests "/grpctest.ItemService/GetItem" with payload from table:
+            | id | 42 |
+        And the grpc service responds with payload from table:
+            | id   | 42       |
+            | name | Item #42 |
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload from table:
+            | id | 42 |
+
+        Then I should have a grpc response with payload from table:
+            | id   | 42       |
+            | name | Item #42 |
@@ -0,0 +1,73 @@
+Feature: Trace propagation
+
+    Scenario: The application propagates the trace of the client request
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+        And "item-service" should have received a grpc request in the same trace as the client request
+        And the grpc request should have a span "find item"
+        And the grpc request should have spans:
+        """
+        /grpctest.ItemService/GetItem
+            find item
+        """
+
+    Scenario: The application propagates the header of every client request
+        Given "item-service" receives 2 grpc requests "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc request has a header "X-Tenant-Id: tenant-42"
+
+        Then the grpc request header "x-tenant-id" should be propagated to "item-service"
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc request has a header "X-Tenant-Id: tenant-43"
+
+        Then the grpc request header "x-tenant-id" should be propagated to "item-service"
@@ -0,0 +1,43 @@
+Feature: Transcript of the grpc requests
+
+    Scenario: Unexpected response
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc request has a header "locale: en-US"
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #41"
+        }
+        """
+
+    Scenario: Expected error
+        Given "item-service" receives a grpc request "/grpctest.ItemService/ListItems"
+        And the grpc service responds with code "Unavailable" and error "service is unavailable"
+
+        When I request a grpc method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then I should have a grpc response with code "Unavailable" and error "service is unavailable"
@@ -0,0 +1,157 @@
+Feature: Forward unexpected requests to the upstream
+
+    Scenario: Expected request is mocked
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+    Scenario: Request does not match the expectation
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+        And the grpc service responds with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 43
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 43,
+            "name": "Upstream Item #43"
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 42
+        }
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "id": 42,
+            "name": "Item #42"
+        }
+        """
+
+    Scenario: Upstream error is relayed
+        When I request a grpc method "/grpctest.ItemService/GetItem" with payload:
+        """
+        {
+            "id": 44
+        }
+        """
+
+        Then I should have a grpc response with code "NotFound" and error "Item #44 not found"
+
+    Scenario: Server stream is forwarded
+        When I request a grpc method "/grpctest.ItemService/ListItems" with payload:
+        """
+        {}
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        [
+            {
+                "id": 40,
+                "name": "Upstream Item #40"
+            },
+            {
+                "id": 41,
+                "name": "Upstream Item #41"
+            }
+        ]
+        """
+
+    Scenario: Client stream is forwarded
+        Given "item-service" receives a grpc request "/grpctest.ItemService/CreateItems" with payload:
+        """
+        [
+            {
+                "id": 42
+            }
+        ]
+        """
+        And the grpc service responds with payload:
+        """
+        {
+            "num_items": 42
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/CreateItems" with payload:
+        """
+        [
+            {
+                "id": 40
+            },
+            {
+                "id": 41
+            }
+        ]
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "num_items": 2
+        }
+        """
+
+        When I request a grpc method "/grpctest.ItemService/CreateItems" with payload:
+        """
+        [
+            {
+                "id": 42
+            }
+        ]
+        """
+
+        Then I should have a grpc response with payload:
+        """
+        {
+            "num_items": 42
+        }
+        """
@@ -0,0 +1,18 @@
+Feature: Recorded interactions of "item-service"
+
+    Scenario: Unary requests
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload from file "features/server/recorded/item-service/0001-GetItem.request.json"
+        And the grpc service responds with payload from file "features/server/recorded/item-service/0001-GetItem.response.json"
+
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload from file "features/server/recorded/item-service/0002-GetItem.request.json"
+        And the grpc service responds with code "NotFound" and error:
+        """
+        Item #44 not found
+        """
+
+    Scenario: Streams
+        Given "item-service" receives a grpc request "/grpctest.ItemService/ListItems" with payload from file "features/server/recorded/item-service/0003-ListItems.request.json"
+        And the grpc service responds with payload from file "features/server/recorded/item-service/0003-ListItems.response.json"
+
+        Given "item-service" receives a grpc request "/grpctest.ItemService/CreateItems" with payload from file "features/server/recorded/item-service/0004-CreateItems.request.json"
+        And the grpc service responds with payload from file "features/server/recorded/item-service/0004-CreateItems.response.json"
@@ -64,12 +64,30 @@ func featureFiles(paths ...string) suiteOption {
 	}
 }
 
+func format(name string) suiteOption {
+	return func(ts *godog.TestSuite) {
+		ts.Options.Format = name
+	}
+}
+
 func noColors() suiteOption {
 	return func(ts *godog.TestSuite) {
 		ts.Options.NoColors = true
 	}
 }
 
+func noRandomize() suiteOption {
+	return func(ts *godog.TestSuite) {
+		ts.Options.Randomize = 0
+	}
+}
+
+func concurrency(n int) suiteOption {
+	return func(ts *godog.TestSuite) {
+		ts.Options.Concurrency = n
+	}
+}
+
 type testT struct {
 	error error
 }
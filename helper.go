@@ -3,18 +3,23 @@ package grpcsteps
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 
 	xreflect "go.nhat.io/grpcmock/reflect"
 	"go.nhat.io/grpcmock/service"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 var (
 	matchFirstCap = regexp.MustCompile("(.)([A-Z][a-z]+)")
 	matchAllCap   = regexp.MustCompile("([a-z0-9])([A-Z])")
+
+	matchMessageSize = regexp.MustCompile(`^([0-9]+)\s*(?i:(?:([kmg])i?)?b?)$`)
 )
 
 func unmarshal(in interface{}, isSlice bool, data *string) (interface{}, error) {
@@ -50,7 +55,18 @@ func toPayload(methodType service.Type, in interface{}, data *string) (interface
 	return unmarshal(in, isSlice, data)
 }
 
+// toStatusCode parses a status code from its name, like "DeadlineExceeded" or "DEADLINE_EXCEEDED", or its number.
 func toStatusCode(data string) (codes.Code, error) {
+	data = strings.TrimSpace(data)
+
+	if n, err := strconv.ParseUint(data, 10, 32); err == nil {
+		if n > uint64(codes.Unauthenticated) {
+			return codes.Unknown, fmt.Errorf("invalid code: %d", n) // nolint: goerr113
+		}
+
+		return codes.Code(n), nil
+	}
+
 	data = fmt.Sprintf("%q", toUpperSnakeCase(data))
 
 	var code codes.Code
@@ -62,6 +78,65 @@ func toStatusCode(data string) (codes.Code, error) {
 	return code, nil
 }
 
+// toStatusCodes parses a comma separated list of status codes, like "Unavailable, DeadlineExceeded".
+func toStatusCodes(data string) ([]codes.Code, error) {
+	values := strings.Split(data, ",")
+	result := make([]codes.Code, 0, len(values))
+
+	for _, v := range values {
+		code, err := toStatusCode(v)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, code)
+	}
+
+	return result, nil
+}
+
+func toHealthStatus(s string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	status, ok := healthpb.HealthCheckResponse_ServingStatus_value[toUpperSnakeCase(s)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidHealthStatus, s)
+	}
+
+	return healthpb.HealthCheckResponse_ServingStatus(status), nil
+}
+
+// toMessageSize parses a number of bytes, with an optional unit, for example "512", "64KB" or "16MiB". The units are
+// multiples of 1024.
+func toMessageSize(s string) (int, error) {
+	m := matchMessageSize.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMessageSize, s)
+	}
+
+	size, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMessageSize, s)
+	}
+
+	var shift uint
+
+	switch strings.ToUpper(m[2]) {
+	case "K":
+		shift = 10
+
+	case "M":
+		shift = 20
+
+	case "G":
+		shift = 30
+	}
+
+	if size > math.MaxInt>>shift {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMessageSize, s)
+	}
+
+	return size << shift, nil
+}
+
 func toUpperSnakeCase(str string) string {
 	snake := matchFirstCap.ReplaceAllString(str, "${1}_${2}")
 	snake = matchAllCap.ReplaceAllString(snake, "${1}_${2}")
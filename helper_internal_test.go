@@ -125,6 +125,17 @@ func TestToStatusCode(t *testing.T) {
 			code:           "DeadlineExceeded",
 			expectedResult: codes.DeadlineExceeded,
 		},
+		{
+			scenario:       "number",
+			code:           "14",
+			expectedResult: codes.Unavailable,
+		},
+		{
+			scenario:       "unknown number",
+			code:           "42",
+			expectedResult: codes.Unknown,
+			expectedError:  `invalid code: 42`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -145,6 +156,129 @@ func TestToStatusCode(t *testing.T) {
 	}
 }
 
+func TestToStatusCodes(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		codes          string
+		expectedResult []codes.Code
+		expectedError  string
+	}{
+		{
+			scenario:      "invalid code",
+			codes:         "Unavailable, not a code",
+			expectedError: `invalid code: "\"NOT A CODE\""`,
+		},
+		{
+			scenario:       "one code",
+			codes:          "Unavailable",
+			expectedResult: []codes.Code{codes.Unavailable},
+		},
+		{
+			scenario:       "several codes",
+			codes:          "Unavailable, DEADLINE_EXCEEDED,1",
+			expectedResult: []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.Canceled},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := toStatusCodes(tc.codes)
+
+			assert.Equal(t, tc.expectedResult, result)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestToMessageSize(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		size           string
+		expectedResult int
+		expectedError  string
+	}{
+		{
+			scenario:      "not a size",
+			size:          "large",
+			expectedError: `invalid message size: "large"`,
+		},
+		{
+			scenario:      "negative",
+			size:          "-1",
+			expectedError: `invalid message size: "-1"`,
+		},
+		{
+			scenario:      "unknown unit",
+			size:          "16TB",
+			expectedError: `invalid message size: "16TB"`,
+		},
+		{
+			scenario:       "bytes",
+			size:           "512",
+			expectedResult: 512,
+		},
+		{
+			scenario:       "bytes with unit",
+			size:           "512B",
+			expectedResult: 512,
+		},
+		{
+			scenario:       "kilobytes",
+			size:           "64KB",
+			expectedResult: 64 * 1024,
+		},
+		{
+			scenario:       "megabytes",
+			size:           "16 MB",
+			expectedResult: 16 * 1024 * 1024,
+		},
+		{
+			scenario:       "mebibytes",
+			size:           "16MiB",
+			expectedResult: 16 * 1024 * 1024,
+		},
+		{
+			scenario:       "gigabytes",
+			size:           "1g",
+			expectedResult: 1024 * 1024 * 1024,
+		},
+		{
+			scenario:      "overflow",
+			size:          "9007199254740992GB",
+			expectedError: `invalid message size: "9007199254740992GB"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := toMessageSize(tc.size)
+
+			assert.Equal(t, tc.expectedResult, result)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
 func TestToUpperSnakeCase(t *testing.T) {
 	t.Parallel()
 
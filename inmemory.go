@@ -0,0 +1,101 @@
+package grpcsteps
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const inMemoryBufferSize = 1024 * 1024
+
+// InMemoryServer serves a grpc server in memory, so the client could test it without opening a port.
+type InMemoryServer struct {
+	*grpc.Server
+
+	listener *bufconn.Listener
+}
+
+// Addr returns the address of the in-memory listener.
+func (s *InMemoryServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// DialContext connects to the server in memory.
+func (s *InMemoryServer) DialContext(ctx context.Context) (net.Conn, error) {
+	return s.listener.DialContext(ctx)
+}
+
+// Close stops the server.
+func (s *InMemoryServer) Close() {
+	s.Server.Stop()
+}
+
+// ServeInMemory starts serving the grpc server in memory.
+//
+//	srv := grpc.NewServer()
+//	grpctest.RegisterItemServiceServer(srv, &itemService{})
+//
+//	s := grpcsteps.ServeInMemory(srv)
+//	defer s.Close()
+//
+//	c := grpcsteps.NewClient(
+//		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer, grpcsteps.WithInMemoryServer(s)),
+//	)
+func ServeInMemory(srv *grpc.Server) *InMemoryServer {
+	l := bufconn.Listen(inMemoryBufferSize)
+
+	go func() {
+		_ = srv.Serve(l) // nolint: errcheck
+	}()
+
+	return &InMemoryServer{
+		Server:   srv,
+		listener: l,
+	}
+}
+
+// WithInMemoryServer connects the service to a server that is served in memory. The connection is insecure.
+func WithInMemoryServer(s *InMemoryServer) ServiceOption {
+	// The methods of the service share the dial options, and so the connection.
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.DialContext(ctx)
+		}),
+	}
+
+	return func(svc *Service) {
+		svc.Address = s.Addr().String()
+		svc.DialOptions = append(svc.DialOptions, opts...)
+	}
+}
+
+// WithExternalService connects the service to a service of the manager, in memory if the manager uses the in-memory
+// transport, or with TCP otherwise. The service is looked up when the client connects, so it could be added to the
+// manager after the client is created. The connection is insecure, and the requests send the scenario, see
+// WithScenarioHeader().
+//
+//	m := grpcsteps.NewExternalServiceManager(grpcsteps.WithInMemoryTransport())
+//	m.AddService("item-service", grpcmock.RegisterService(grpctest.RegisterItemServiceServer))
+//
+//	c := grpcsteps.NewClient(
+//		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer, grpcsteps.WithExternalService(m, "item-service")),
+//	)
+func WithExternalService(m *ExternalServiceManager, id string) ServiceOption {
+	// The methods of the service share the dial options, and so the connection.
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return m.dial(ctx, id)
+		}),
+	}
+
+	return func(svc *Service) {
+		svc.Address = id
+		svc.DialOptions = append(svc.DialOptions, opts...)
+		svc.SendScenario = true
+	}
+}
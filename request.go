@@ -2,29 +2,123 @@ package grpcsteps
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/cucumber/godog"
+	"google.golang.org/grpc/metadata"
 )
 
 // ErrNoRequestPlannerInContext indicates that there is no request planner in context.
 const ErrNoRequestPlannerInContext err = "no request planner in context"
 
+const binaryHeaderSuffix = "-bin"
+
 type requestPlanner interface {
 	WithHeader(header string, value interface{}) error
+	WithHeaders(header metadata.MD) error
 	WithTimeout(d time.Duration) error
 }
 
+var (
+	requestHeaderStep  = regexp.MustCompile(`^[tT]he (?:gRPC|GRPC|grpc) request has(?: a)? header "([^"]*): ([^"]*)"$`)
+	requestHeadersStep = regexp.MustCompile(`^[tT]he (?:gRPC|GRPC|grpc) request has headers:$`)
+	requestTimeoutStep = regexp.MustCompile(`^[tT]he (?:gRPC|GRPC|grpc) request timeout is "([^"]*)"$`)
+)
+
+// registerRequestPlanner registers the steps that plan the request. The client and the external service manager share
+// them, and godog rejects the steps that are registered twice in the strict mode, so they are registered once per
+// scenario context.
 func registerRequestPlanner(sc *godog.ScenarioContext) {
-	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request has(?: a)? header "([^"]*): ([^"]*)"$`, planRequestWithHeader)
-	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request timeout is "([^"]*)"$`, planRequestWithTimeout)
+	if hasStep(sc, requestHeaderStep) {
+		return
+	}
+
+	sc.Step(requestHeaderStep, planRequestWithHeader)
+	sc.Step(requestHeadersStep, planRequestWithHeaders)
+	sc.Step(requestTimeoutStep, planRequestWithTimeout)
+}
+
+// hasStep tells whether the step is registered to the scenario context. godog does not expose the steps, so they are
+// read with reflection, and the step is not registered if they cannot be read.
+func hasStep(sc *godog.ScenarioContext, expr *regexp.Regexp) bool {
+	suite := reflect.ValueOf(sc).Elem().FieldByName("suite")
+	if suite.Kind() != reflect.Ptr || suite.IsNil() {
+		return false
+	}
+
+	steps := suite.Elem().FieldByName("steps")
+	if steps.Kind() != reflect.Slice {
+		return false
+	}
+
+	for i := 0; i < steps.Len(); i++ {
+		step := reflect.Indirect(steps.Index(i))
+		if step.Kind() != reflect.Struct {
+			continue
+		}
+
+		if e := step.FieldByName("Expr"); e.Kind() == reflect.Ptr && e.Pointer() == reflect.ValueOf(expr).Pointer() {
+			return true
+		}
+	}
+
+	return false
 }
 
 func planRequestWithHeader(ctx context.Context, header, value string) error {
 	return requestPlannerFromContext(ctx).WithHeader(header, value)
 }
 
+func planRequestWithHeaders(ctx context.Context, table *godog.Table) error {
+	header, err := tableHeader(table)
+	if err != nil {
+		return err
+	}
+
+	return requestPlannerFromContext(ctx).WithHeaders(header)
+}
+
+// tableHeader reads the header from a table of keys and values. A key could be repeated for several values, and the
+// values of the binary headers, the ones with the "-bin" suffix, are base64 encoded.
+func tableHeader(table *godog.Table) (metadata.MD, error) {
+	header := metadata.MD{}
+
+	for i, row := range table.Rows {
+		if len(row.Cells) != 2 {
+			return nil, fmt.Errorf("%w: row %d has %d cells, a key and a value expected", ErrInvalidHeader, i+1, len(row.Cells))
+		}
+
+		key, value := row.Cells[0].Value, row.Cells[1].Value
+
+		if strings.HasSuffix(strings.ToLower(key), binaryHeaderSuffix) {
+			b, err := decodeBinaryHeader(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not base64 encoded: %s", ErrInvalidHeader, key, err.Error())
+			}
+
+			value = string(b)
+		}
+
+		header.Append(key, value)
+	}
+
+	return header, nil
+}
+
+// decodeBinaryHeader decodes the base64 value of a binary header, with or without padding, the same way as grpc.
+func decodeBinaryHeader(v string) ([]byte, error) {
+	if len(v)%4 == 0 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+
+	return base64.RawStdEncoding.DecodeString(v)
+}
+
 func planRequestWithTimeout(ctx context.Context, t string) error {
 	timeout, err := time.ParseDuration(t)
 	if err != nil {
@@ -57,6 +151,10 @@ func (missingRequestPlanner) WithHeader(string, interface{}) error {
 	return missingRequestPlannerErr()
 }
 
+func (missingRequestPlanner) WithHeaders(metadata.MD) error {
+	return missingRequestPlannerErr()
+}
+
 func (missingRequestPlanner) WithTimeout(time.Duration) error {
 	return missingRequestPlannerErr()
 }
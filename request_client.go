@@ -2,17 +2,22 @@ package grpcsteps
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"sync"
 	"time"
 
+	"github.com/cucumber/godog"
 	"github.com/swaggest/assertjson"
 	"go.nhat.io/grpcmock"
-	"go.nhat.io/grpcmock/invoker"
 	"go.nhat.io/grpcmock/must"
 	xreflect "go.nhat.io/grpcmock/reflect"
 	"go.nhat.io/grpcmock/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 // ErrNoClientRequestInContext indicates that there is no client request in context.
@@ -23,18 +28,40 @@ type clientRequest interface {
 }
 
 type clientRequestInvoker struct {
-	invoker     *invoker.Invoker
-	response    []byte
-	responseRaw interface{}
-	responseErr error
+	conn       func(ctx context.Context) (*grpc.ClientConn, error)
+	scenario   *godog.Scenario
+	transcript *transcript
+	tracing    *clientTracing
+	address    string
+	method     service.Method
+	input      interface{}
+	header     metadata.MD
+	callOpts   []grpc.CallOption
+	timeout    time.Duration
+
+	response       []byte
+	responseRaw    interface{}
+	responseHeader metadata.MD
+	responseErr    error
+	spanContext    trace.SpanContext
 
 	once sync.Once
 }
 
 func (r *clientRequestInvoker) Do() ([]byte, error) {
 	r.once.Do(func() {
-		r.responseErr = r.invoker.Invoke(context.Background())
-		if r.responseErr != nil {
+		start := time.Now()
+
+		ctx, span := r.tracing.Start(scenarioToContext(context.Background(), r.scenario), r.method.FullName(), r.header)
+		r.spanContext = span.SpanContext()
+
+		r.responseErr = r.invoke(ctx)
+
+		r.tracing.End(span, r.responseErr)
+		r.record(time.Since(start))
+
+		// The messages that a stream receives before an error are kept.
+		if r.responseErr != nil && !r.hasPartialResponse() {
 			return
 		}
 
@@ -47,49 +74,158 @@ func (r *clientRequestInvoker) Do() ([]byte, error) {
 	return r.response, r.responseErr
 }
 
-func newClientRequestInvoker(svc *Service, payload interface{}) *clientRequestInvoker {
-	out := newServerOutput(svc.MethodType, svc.Output)
-	i := invoker.New(svc.Method, clientRequestInvokerOptions(svc, payload, out)...)
+func (r *clientRequestInvoker) hasPartialResponse() bool {
+	v := reflect.ValueOf(r.responseRaw).Elem()
 
-	i.WithTimeout(time.Second)
+	return v.Kind() == reflect.Slice && v.Len() > 0
+}
 
-	return &clientRequestInvoker{
-		invoker:     i,
-		responseRaw: out,
-		responseErr: nil,
+// record adds the request and its response to the transcript of the scenario.
+func (r *clientRequestInvoker) record(d time.Duration) {
+	e := newTranscriptEntry(transcriptSourceClient, r.method.FullName(), r.header, r.responseErr, d)
+	e.Address = r.address
+	e.ResponseHeader = r.responseHeader
+	e.Request = transcriptPayload(r.input)
+
+	if r.responseErr == nil || r.hasPartialResponse() {
+		e.Response = transcriptPayload(r.responseRaw)
 	}
+
+	r.transcript.Record(e)
 }
 
-func clientRequestInvokerOptions(svc *Service, payload interface{}, out interface{}) []invoker.Option {
-	opts := []invoker.Option{
-		invoker.WithAddress(svc.Address),
+func (r *clientRequestInvoker) invoke(ctx context.Context) error {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
 	}
 
-	switch svc.MethodType {
+	conn, err := r.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(r.header) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, r.header)
+	}
+
+	method := r.method.FullName()
+	callOpts := append(r.callOpts[:len(r.callOpts):len(r.callOpts)], grpc.Header(&r.responseHeader))
+
+	switch r.method.MethodType {
 	case service.TypeBidirectionalStream:
-		opts = append(opts, invoker.WithBidirectionalStreamHandler(grpcmock.SendAndRecvAll(payload, out)))
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		s, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, method, callOpts...)
+		if err != nil {
+			return err
+		}
+
+		return sendAndRecvAll(s, cancel, r.input, r.responseRaw)
 
 	case service.TypeClientStream:
-		opts = append(opts, invoker.WithInputStreamHandler(grpcmock.SendAll(payload)),
-			invoker.WithOutput(out),
-		)
+		s, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, method, callOpts...)
+		if err != nil {
+			return err
+		}
+
+		if err := grpcmock.SendAll(r.input)(s); err != nil {
+			return err
+		}
+
+		if err := s.CloseSend(); err != nil {
+			return err
+		}
+
+		return s.RecvMsg(r.responseRaw)
 
 	case service.TypeServerStream:
-		opts = append(opts, invoker.WithInput(payload),
-			invoker.WithOutputStreamHandler(grpcmock.RecvAll(out)),
-		)
+		s, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, method, callOpts...)
+		if err != nil {
+			return err
+		}
+
+		if err := s.SendMsg(r.input); err != nil {
+			return err
+		}
+
+		if err := s.CloseSend(); err != nil {
+			return err
+		}
+
+		return recvAll(s, r.responseRaw)
 
 	case service.TypeUnary:
 		fallthrough
 	default:
-		opts = append(opts, invoker.WithInput(payload),
-			invoker.WithOutput(out),
-		)
+		return conn.Invoke(ctx, method, r.input, r.responseRaw, callOpts...)
 	}
+}
+
+// recvAll receives the messages of a stream until the server closes it. Unlike grpcmock.RecvAll, the messages received
+// before an error are kept.
+func recvAll(s grpc.ClientStream, out interface{}) error {
+	messages := reflect.ValueOf(out).Elem()
+	msgType := messages.Type().Elem().Elem()
+
+	for {
+		msg := reflect.New(msgType)
 
-	opts = append(opts, invoker.WithDialOptions(svc.DialOptions...))
+		if err := s.RecvMsg(msg.Interface()); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
 
-	return opts
+			return err
+		}
+
+		messages.Set(reflect.Append(messages, msg))
+	}
+}
+
+// sendAndRecvAll sends the messages of a bidirectional stream while receiving the messages from the server. The stream
+// is canceled if the messages could not be sent.
+func sendAndRecvAll(s grpc.ClientStream, cancel context.CancelFunc, in, out interface{}) error {
+	recvErr := make(chan error, 1)
+
+	go func() {
+		recvErr <- recvAll(s, out)
+	}()
+
+	sendErr := grpcmock.SendAll(in)(s)
+	if sendErr == nil {
+		sendErr = s.CloseSend()
+	}
+
+	// io.EOF means that the server closed the stream, the reason is the error of the receiver.
+	if sendErr != nil && !errors.Is(sendErr, io.EOF) {
+		cancel()
+		<-recvErr
+
+		return sendErr
+	}
+
+	return <-recvErr
+}
+
+// newClientRequestInvoker creates a request that is sent with a shared connection of the client.
+func newClientRequestInvoker(c *Client, svc *Service, payload interface{}) *clientRequestInvoker {
+	return &clientRequestInvoker{
+		conn: func(ctx context.Context) (*grpc.ClientConn, error) {
+			return c.conn(ctx, svc)
+		},
+		tracing:     c.tracing,
+		address:     svc.Address,
+		method:      svc.Method,
+		input:       payload,
+		header:      metadata.MD{},
+		callOpts:    append([]grpc.CallOption(nil), svc.CallOptions...),
+		timeout:     c.requestTimeout(svc),
+		responseRaw: newServerOutput(svc.MethodType, svc.Output),
+	}
 }
 
 type missingClientRequest struct{}
@@ -107,6 +243,19 @@ func clientRequestFromContext(ctx context.Context) clientRequest {
 	return r
 }
 
+// sentClientRequest sends the client request in context, unless it is already sent. The errors of the request are
+// asserted by the other steps.
+func sentClientRequest(ctx context.Context) (*clientRequestInvoker, error) {
+	r, ok := clientRequestFromContext(ctx).(*clientRequestInvoker)
+	if !ok {
+		return nil, missingClientRequestPlannerErr()
+	}
+
+	_, _ = r.Do() // nolint: errcheck
+
+	return r, nil
+}
+
 func clientRequestToContext(ctx context.Context, r clientRequest) context.Context {
 	return context.WithValue(ctx, requestCtxKey{}, r)
 }
@@ -115,14 +264,36 @@ type clientRequestPlanner struct {
 	request *clientRequestInvoker
 }
 
+// WithHeader adds the value to the header, the header could have several values.
 func (c clientRequestPlanner) WithHeader(header string, value interface{}) error {
-	c.request.invoker.WithInvokeOption(grpcmock.WithHeader(header, value.(string)))
+	c.request.header.Append(header, value.(string))
+
+	return nil
+}
+
+// WithHeaders sets the headers to the values of the table, the values that are set before are replaced.
+func (c clientRequestPlanner) WithHeaders(header metadata.MD) error {
+	for k, v := range header {
+		c.request.header.Set(k, v...)
+	}
 
 	return nil
 }
 
 func (c clientRequestPlanner) WithTimeout(d time.Duration) error {
-	c.request.invoker.WithTimeout(d)
+	c.request.timeout = d
+
+	return nil
+}
+
+// planClientRequestWithCallOptions adds call options to the client request in the context.
+func planClientRequestWithCallOptions(ctx context.Context, opts ...grpc.CallOption) error {
+	r, ok := clientRequestFromContext(ctx).(*clientRequestInvoker)
+	if !ok {
+		return missingClientRequestPlannerErr()
+	}
+
+	r.callOpts = append(r.callOpts, opts...)
 
 	return nil
 }
@@ -133,8 +304,18 @@ func newClientRequestPlanner(req *clientRequestInvoker) *clientRequestPlanner {
 	}
 }
 
-func newClientRequestPlannerContext(ctx context.Context, svc *Service, payload interface{}) context.Context {
-	r := newClientRequestInvoker(svc, payload)
+func newClientRequestPlannerContext(ctx context.Context, c *Client, svc *Service, payload interface{}) context.Context {
+	r := newClientRequestInvoker(c, svc, payload)
+
+	if sc := ScenarioFromContext(ctx); sc != nil {
+		r.scenario = sc
+
+		if svc.SendScenario {
+			r.header.Set(ScenarioHeader, sc.Id)
+		}
+	}
+
+	r.transcript = transcriptFromContext(ctx)
 
 	ctx = requestPlannerToContext(ctx, newClientRequestPlanner(r))
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.nhat.io/grpcmock/service"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/godogx/grpcsteps/internal/grpctest"
 )
@@ -24,6 +25,24 @@ func TestClientRequestInContext(t *testing.T) {
 	assert.Equal(t, &clientRequestInvoker{}, clientRequestFromContext(ctx))
 }
 
+func TestClientRequestPlanner_WithHeader(t *testing.T) {
+	t.Parallel()
+
+	r := &clientRequestInvoker{header: metadata.MD{}}
+	p := clientRequestPlanner{request: r}
+
+	// The single header steps add the values.
+	assert.NoError(t, p.WithHeader("Locale", "en-US"))
+	assert.NoError(t, p.WithHeader("locale", "fr-FR"))
+
+	assert.Equal(t, []string{"en-US", "fr-FR"}, r.header.Get("locale"))
+
+	// The table replaces them.
+	assert.NoError(t, p.WithHeaders(metadata.Pairs("locale", "de-DE")))
+
+	assert.Equal(t, []string{"de-DE"}, r.header.Get("locale"))
+}
+
 func TestMissingClientRequest(t *testing.T) {
 	t.Parallel()
 
@@ -4,7 +4,9 @@ import (
 	"context"
 	"testing"
 
+	"github.com/cucumber/godog"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestPlanRequestWithTimeout(t *testing.T) {
@@ -16,6 +18,72 @@ func TestPlanRequestWithTimeout(t *testing.T) {
 	assert.EqualError(t, err, expected)
 }
 
+func TestTableHeader(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		table          *godog.Table
+		expectedResult metadata.MD
+		expectedError  string
+	}{
+		{
+			scenario:       "no header",
+			table:          newTable(),
+			expectedResult: metadata.MD{},
+		},
+		{
+			scenario: "several values",
+			table: newTable(
+				[]string{"Locale", "en-US"},
+				[]string{"Tag", "foo"},
+				[]string{"tag", "bar"},
+			),
+			expectedResult: metadata.MD{
+				"locale": {"en-US"},
+				"tag":    {"foo", "bar"},
+			},
+		},
+		{
+			scenario: "binary header",
+			table: newTable(
+				[]string{"Trace-Bin", "AQIDBA=="},
+				[]string{"trace-bin", "AQIDBA"},
+			),
+			expectedResult: metadata.MD{
+				"trace-bin": {"\x01\x02\x03\x04", "\x01\x02\x03\x04"},
+			},
+		},
+		{
+			scenario:      "not base64",
+			table:         newTable([]string{"trace-bin", "not base64"}),
+			expectedError: `invalid header: "trace-bin" is not base64 encoded: illegal base64 data at input byte 3`,
+		},
+		{
+			scenario:      "wrong number of cells",
+			table:         newTable([]string{"locale"}),
+			expectedError: `invalid header: row 1 has 1 cells, a key and a value expected`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := tableHeader(tc.table)
+
+			assert.Equal(t, tc.expectedResult, result)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
 func TestRequestPlannerInContext(t *testing.T) {
 	t.Parallel()
 
@@ -38,5 +106,6 @@ func TestMissingRequestPlanner(t *testing.T) {
 	p := missingRequestPlanner{}
 
 	assert.EqualError(t, p.WithHeader("", nil), expected)
+	assert.EqualError(t, p.WithHeaders(nil), expected)
 	assert.EqualError(t, p.WithTimeout(0), expected)
 }
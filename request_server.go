@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/cucumber/godog"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 )
 
 // ErrNoServiceRequestInContext indicates that there is no service request in context.
@@ -15,11 +17,17 @@ type serverRequestPlanner interface {
 	requestPlanner
 
 	Return(payload string) error
+	ReturnTable(table *godog.Table) error
 	ReturnError(code codes.Code, message string) error
+	ReturnInSequence(responses []sequencedResponse) error
+	WithCompression(name string) error
+	WhenInState(state string) error
+	MoveToState(state string) error
 }
 
 type serverRequestReflectorPlanner struct {
 	expected expectation
+	output   messageType
 }
 
 func (s *serverRequestReflectorPlanner) WithHeader(header string, value interface{}) error {
@@ -28,6 +36,15 @@ func (s *serverRequestReflectorPlanner) WithHeader(header string, value interfac
 	return nil
 }
 
+// WithHeaders expects the exact values of every header, whether it has one value or several.
+func (s *serverRequestReflectorPlanner) WithHeaders(header metadata.MD) error {
+	for k, v := range header {
+		s.expected.WithHeaderValues(k, v)
+	}
+
+	return nil
+}
+
 func (s *serverRequestReflectorPlanner) WithTimeout(time.Duration) error {
 	return fmt.Errorf("grpc service request does not have timeout") // nolint: goerr113
 }
@@ -38,15 +55,51 @@ func (s *serverRequestReflectorPlanner) Return(payload string) error { // nolint
 	return nil
 }
 
+func (s *serverRequestReflectorPlanner) ReturnTable(table *godog.Table) error {
+	payload, err := tablePayload(s.output, table)
+	if err != nil {
+		return err
+	}
+
+	s.expected.Return(payload)
+
+	return nil
+}
+
 func (s *serverRequestReflectorPlanner) ReturnError(code codes.Code, message string) error { // nolint: unparam
 	s.expected.ReturnError(code, message)
 
 	return nil
 }
 
-func newServerRequestPlanner(expected expectation) *serverRequestReflectorPlanner {
+func (s *serverRequestReflectorPlanner) ReturnInSequence(responses []sequencedResponse) error { // nolint: unparam
+	s.expected.ReturnInSequence(responses)
+
+	return nil
+}
+
+func (s *serverRequestReflectorPlanner) WithCompression(name string) error { // nolint: unparam
+	s.expected.WithCompression(name)
+
+	return nil
+}
+
+func (s *serverRequestReflectorPlanner) WhenInState(state string) error { // nolint: unparam
+	s.expected.WhenInState(state)
+
+	return nil
+}
+
+func (s *serverRequestReflectorPlanner) MoveToState(state string) error { // nolint: unparam
+	s.expected.MoveToState(state)
+
+	return nil
+}
+
+func newServerRequestPlanner(expected expectation, output messageType) *serverRequestReflectorPlanner {
 	return &serverRequestReflectorPlanner{
 		expected: expected,
+		output:   output,
 	}
 }
 
@@ -59,8 +112,8 @@ func serverRequestPlannerFromContext(ctx context.Context) serverRequestPlanner {
 	return r
 }
 
-func newServerRequestPlannerContext(ctx context.Context, expected expectation) context.Context {
-	return requestPlannerToContext(ctx, newServerRequestPlanner(expected))
+func newServerRequestPlannerContext(ctx context.Context, expected expectation, output messageType) context.Context {
+	return requestPlannerToContext(ctx, newServerRequestPlanner(expected, output))
 }
 
 type missingServerRequestPlanner struct{}
@@ -69,6 +122,10 @@ func (missingServerRequestPlanner) WithHeader(string, interface{}) error {
 	return missingServerRequestPlannerErr()
 }
 
+func (missingServerRequestPlanner) WithHeaders(metadata.MD) error {
+	return missingServerRequestPlannerErr()
+}
+
 func (missingServerRequestPlanner) WithTimeout(time.Duration) error {
 	return missingServerRequestPlannerErr()
 }
@@ -77,10 +134,30 @@ func (missingServerRequestPlanner) Return(string) error {
 	return missingServerRequestPlannerErr()
 }
 
+func (missingServerRequestPlanner) ReturnTable(*godog.Table) error {
+	return missingServerRequestPlannerErr()
+}
+
 func (missingServerRequestPlanner) ReturnError(codes.Code, string) error {
 	return missingServerRequestPlannerErr()
 }
 
+func (missingServerRequestPlanner) ReturnInSequence([]sequencedResponse) error {
+	return missingServerRequestPlannerErr()
+}
+
+func (missingServerRequestPlanner) WithCompression(string) error {
+	return missingServerRequestPlannerErr()
+}
+
+func (missingServerRequestPlanner) WhenInState(string) error {
+	return missingServerRequestPlannerErr()
+}
+
+func (missingServerRequestPlanner) MoveToState(string) error {
+	return missingServerRequestPlannerErr()
+}
+
 func missingServerRequestPlannerErr() error {
 	//goland:noinspection GoErrorStringFormat
 	return fmt.Errorf(
@@ -10,7 +10,7 @@ import (
 func TestServerRequestReflectorPlanner_WithTimeout(t *testing.T) {
 	t.Parallel()
 
-	p := newServerRequestPlanner((*unaryExpectation)(nil))
+	p := newServerRequestPlanner((*unaryExpectation)(nil), messageType{})
 
 	err := p.WithTimeout(0)
 
@@ -28,7 +28,7 @@ func TestServerRequestPlannerInContext(t *testing.T) {
 	assert.Equal(t, missingServerRequestPlanner{}, serverRequestPlannerFromContext(ctx))
 
 	// Case 2: request in context.
-	ctx = newServerRequestPlannerContext(ctx, nil)
+	ctx = newServerRequestPlannerContext(ctx, nil, messageType{})
 
 	assert.Equal(t, &serverRequestReflectorPlanner{}, serverRequestPlannerFromContext(ctx))
 }
@@ -51,7 +51,12 @@ For example:
 	p := missingServerRequestPlanner{}
 
 	assert.EqualError(t, p.WithHeader("", nil), expected)
+	assert.EqualError(t, p.WithHeaders(nil), expected)
 	assert.EqualError(t, p.WithTimeout(0), expected)
 	assert.EqualError(t, p.Return(""), expected)
+	assert.EqualError(t, p.ReturnTable(nil), expected)
 	assert.EqualError(t, p.ReturnError(0, ""), expected)
+	assert.EqualError(t, p.ReturnInSequence(nil), expected)
+	assert.EqualError(t, p.WhenInState(""), expected)
+	assert.EqualError(t, p.MoveToState(""), expected)
 }
@@ -1,81 +1,191 @@
 package grpcsteps
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cucumber/godog"
 	"go.nhat.io/grpcmock"
+	"go.nhat.io/grpcmock/must"
 	"go.nhat.io/grpcmock/planner"
-	"go.nhat.io/grpcmock/service"
+	"golang.org/x/term"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 // ExternalServiceManager is a grpc server for godog.
 type ExternalServiceManager struct {
-	servers map[string]*wrappedServer
+	servers   map[string]*wrappedServer
+	listeners map[string]*bufconn.Listener
+
+	randomSeed int64
+	inMemory   bool
+	reflection bool
+	pause      func(message string)
+
+	mu sync.RWMutex
+}
+
+// ExternalServiceManagerOption sets up an external service manager.
+type ExternalServiceManagerOption func(m *ExternalServiceManager)
+
+// ExternalServiceOption sets up a mocked service of the external service manager, see AddServiceWithOptions().
+type ExternalServiceOption func(c *serviceConfig)
+
+// serviceConfig is the configuration of a mocked service.
+type serviceConfig struct {
+	serverOptions       []grpcmock.ServerOption
+	upstreamAddr        string
+	upstreamDialOptions []grpc.DialOption
+	recordDir           string
+	replayDir           string
+	dynamic             *dynamicServices
+	health              bool
+	reflection          bool
+	credentials         credentials.TransportCredentials
 }
 
 // RegisterContext registers to godog scenario.
 func (m *ExternalServiceManager) RegisterContext(sc *godog.ScenarioContext) {
-	sc.Before(func(context.Context, *godog.Scenario) (context.Context, error) {
-		m.resetExpectations()
+	sc.Before(func(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+		ctx, t := withTranscript(ctx, sc)
 
-		return nil, nil
-	})
-
-	sc.After(func(ctx context.Context, _ *godog.Scenario, err error) (context.Context, error) {
-		if err != nil {
-			return ctx, nil // nolint: nilerr
-		}
+		m.startScenario(sc, t)
 
-		return ctx, m.assertExpectationsWereMet()
+		return scenarioToContext(ctx, sc), nil
 	})
 
+	sc.After(m.afterScenario)
+
 	sc.Step(`^"([^"]*)" receives [a1] (?:gRPC|GRPC|grpc) request "([^"]*)"$`, m.receiveOneRequestWithoutPayload)
 	sc.Step(`^"([^"]*)" receives [a1] (?:gRPC|GRPC|grpc) request "([^"]*)" with payload:$`, m.receiveOneRequestWithPayloadFromDocString)
 	sc.Step(`^"([^"]*)" receives [a1] (?:gRPC|GRPC|grpc) request "([^"]*)" with payload from file "([^"]+)"$`, m.receiveOneRequestWithPayloadFromFile)
 	sc.Step(`^"([^"]*)" receives [a1] (?:gRPC|GRPC|grpc) request "([^"]*)" with payload from file:$`, m.receiveOneRequestWithPayloadFromFileDocString)
+	sc.Step(`^"([^"]*)" receives [a1] (?:gRPC|GRPC|grpc) request "([^"]*)" with payload from table:$`, m.receiveOneRequestWithPayloadFromTable)
 
 	sc.Step(`^"([^"]*)" receives ([0-9]+) (?:gRPC|GRPC|grpc) requests "([^"]*)"$`, m.receiveRepeatedRequestsWithoutPayload)
 	sc.Step(`^"([^"]*)" receives ([0-9]+) (?:gRPC|GRPC|grpc) requests "([^"]*)" with payload:$`, m.receiveRepeatedRequestsWithPayloadFromDocString)
 	sc.Step(`^"([^"]*)" receives ([0-9]+) (?:gRPC|GRPC|grpc) requests "([^"]*)" with payload from file "([^"]+)"$`, m.receiveRepeatedRequestsWithPayloadFromFile)
 	sc.Step(`^"([^"]*)" receives ([0-9]+) (?:gRPC|GRPC|grpc) requests "([^"]*)" with payload from file:$`, m.receiveRepeatedRequestsWithPayloadFromFileDocString)
+	sc.Step(`^"([^"]*)" receives ([0-9]+) (?:gRPC|GRPC|grpc) requests "([^"]*)" with payload from table:$`, m.receiveRepeatedRequestsWithPayloadFromTable)
 
 	sc.Step(`^"([^"]*)" receives (?:some|many|several) (?:gRPC|GRPC|grpc) requests "([^"]*)"$`, m.receiveManyRequestsWithoutPayload)
 	sc.Step(`^"([^"]*)" receives (?:some|many|several) (?:gRPC|GRPC|grpc) requests "([^"]*)" with payload:$`, m.receiveManyRequestsWithPayloadFromDocString)
 	sc.Step(`^"([^"]*)" receives (?:some|many|several) (?:gRPC|GRPC|grpc) requests "([^"]*)" with payload from file "([^"]+)"$`, m.receiveManyRequestsWithPayloadFromFile)
 	sc.Step(`^"([^"]*)" receives (?:some|many|several) (?:gRPC|GRPC|grpc) requests "([^"]*)" with payload from file:$`, m.receiveManyRequestsWithPayloadFromFileDocString)
+	sc.Step(`^"([^"]*)" receives (?:some|many|several) (?:gRPC|GRPC|grpc) requests "([^"]*)" with payload from table:$`, m.receiveManyRequestsWithPayloadFromTable)
+
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request is compressed with "([^"]*)"$`, m.receiveCompressedRequest)
 
 	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds with payload:?$`, m.respondWithPayloadFromDocString)
 	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds with payload from file "([^"]+)"$`, m.respondWithPayloadFromFile)
 	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds with payload from file:$`, m.respondWithPayloadFromFileDocString)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds with payload from table:$`, m.respondWithPayloadFromTable)
 	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds with code "([^"]*)"$`, m.respondWithErrorCode)
 	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds with error (?:message )?"([^"]*)"$`, m.respondWithErrorMessage)
 	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds with error(?: message)?:$`, m.respondWithErrorMessageFromDocString)
 	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds with code "([^"]*)" and error (?:message )?"([^"]*)"$`, m.respondWithError)
 	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds with code "([^"]*)" and error(?: message)?:$`, m.respondWithErrorFromDocString)
 
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service responds in sequence:$`, m.respondInSequence)
+
+	sc.Step(`^"([^"]*)" drops the connection on the next (?:gRPC|GRPC|grpc) request$`, m.dropNextConnection)
+	sc.Step(`^"([^"]*)" drops the connection on the next ([0-9]+) (?:gRPC|GRPC|grpc) requests$`, m.dropNextConnections)
+	sc.Step(`^"([^"]*)" fails ([0-9]+(?:\.[0-9]+)?)% of (?:gRPC|GRPC|grpc) requests with code "([^"]*)"$`, m.failRequests)
+	sc.Step(`^"([^"]*)" adds random latency between "([^"]*)" and "([^"]*)"$`, m.addRandomLatency)
+
+	sc.Step(`^"([^"]*)" health status is "([^"]*)"$`, m.setHealthStatus)
+	sc.Step(`^"([^"]*)" health status for service "([^"]*)" is "([^"]*)"$`, m.setServiceHealthStatus)
+
+	sc.Step(`^"([^"]*)" is in state "([^"]*)"$`, m.setServiceState)
+	sc.Step(`^"([^"]*)" should be in state "([^"]*)"$`, m.assertServiceState)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request is expected when in state "([^"]*)"$`, m.expectWhenInState)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) service (?:moves|will move) to state "([^"]*)"$`, m.moveToState)
+
+	sc.Step(`^"([^"]*)" should have received(?: a)? (?:gRPC|GRPC|grpc) request in the same trace as the client request$`, m.assertReceivedInClientTrace)
+	sc.Step(`^[tT]he (?:gRPC|GRPC|grpc) request header "([^"]*)" should be propagated to "([^"]*)"$`, m.assertReceivedWithClientHeader)
+	sc.Step(`^"([^"]*)" should (?:have received|receive)(?: a)? (?:gRPC|GRPC|grpc) request with a deadline$`, m.assertReceivedWithDeadline)
+	sc.Step(`^"([^"]*)" should (?:have received|receive)(?: a)? (?:gRPC|GRPC|grpc) request with remaining deadline less than "([^"]*)"$`, m.assertReceivedWithRemainingDeadline)
+
 	registerRequestPlanner(sc)
 }
 
-func (m *ExternalServiceManager) receiveRequest(ctx context.Context, serviceID, method string, times uint, payload *string) (context.Context, error) {
+func (m *ExternalServiceManager) server(serviceID string) (*wrappedServer, error) {
+	m.mu.RLock()
 	srv, found := m.servers[serviceID]
+	m.mu.RUnlock()
+
 	if !found {
 		//goland:noinspection GoErrorStringFormat
-		return ctx, fmt.Errorf(
+		return nil, fmt.Errorf(
 			"%w, did you forget to setup the grpc service %q?",
 			ErrGRPCServiceNotFound, serviceID,
 		)
 	}
 
-	r, err := srv.expect(method, times, payload)
+	return srv, nil
+}
+
+// scope returns the scope of the service in the scenario of the context.
+func (m *ExternalServiceManager) scope(ctx context.Context, serviceID string) (*serviceScope, error) {
+	srv, err := m.server(serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return srv.scope(scenarioFromContext(ctx)), nil
+}
+
+func (m *ExternalServiceManager) receiveRequest(ctx context.Context, serviceID, method string, times uint, payload *string) (context.Context, error) {
+	srv, err := m.server(serviceID)
+	if err != nil {
+		return ctx, err
+	}
+
+	r, err := srv.scope(scenarioFromContext(ctx)).expect(method, times, payload)
+	if err != nil {
+		return ctx, err
+	}
+
+	_, out, err := srv.messageTypes(method)
 	if err != nil {
 		return ctx, err
 	}
 
-	return newServerRequestPlannerContext(ctx, r), nil
+	return newServerRequestPlannerContext(ctx, r, out), nil
+}
+
+func (m *ExternalServiceManager) receiveRequestWithTable(ctx context.Context, serviceID, method string, times uint, table *godog.Table) (context.Context, error) {
+	srv, err := m.server(serviceID)
+	if err != nil {
+		return ctx, err
+	}
+
+	in, _, err := srv.messageTypes(method)
+	if err != nil {
+		return ctx, err
+	}
+
+	payload, err := tablePayload(in, table)
+	if err != nil {
+		return ctx, err
+	}
+
+	return m.receiveRequest(ctx, serviceID, method, times, &payload)
 }
 
 func (m *ExternalServiceManager) receiveOneRequestWithoutPayload(ctx context.Context, service, method string) (context.Context, error) {
@@ -103,6 +213,10 @@ func (m *ExternalServiceManager) receiveOneRequestWithPayloadFromFileDocString(c
 	return m.receiveOneRequestWithPayloadFromFile(ctx, service, method, path.Content)
 }
 
+func (m *ExternalServiceManager) receiveOneRequestWithPayloadFromTable(ctx context.Context, service, method string, table *godog.Table) (context.Context, error) {
+	return m.receiveRequestWithTable(ctx, service, method, 1, table)
+}
+
 func (m *ExternalServiceManager) receiveRepeatedRequestsWithoutPayload(ctx context.Context, service string, times int, method string) (context.Context, error) {
 	return m.receiveRequest(ctx, service, method, uint(times), nil)
 }
@@ -128,6 +242,10 @@ func (m *ExternalServiceManager) receiveRepeatedRequestsWithPayloadFromFileDocSt
 	return m.receiveRepeatedRequestsWithPayloadFromFile(ctx, service, times, method, path.Content)
 }
 
+func (m *ExternalServiceManager) receiveRepeatedRequestsWithPayloadFromTable(ctx context.Context, service string, times int, method string, table *godog.Table) (context.Context, error) {
+	return m.receiveRequestWithTable(ctx, service, method, uint(times), table)
+}
+
 func (m *ExternalServiceManager) receiveManyRequestsWithoutPayload(ctx context.Context, service, method string) (context.Context, error) {
 	return m.receiveRequest(ctx, service, method, planner.UnlimitedTimes, nil)
 }
@@ -153,6 +271,14 @@ func (m *ExternalServiceManager) receiveManyRequestsWithPayloadFromFileDocString
 	return m.receiveManyRequestsWithPayloadFromFile(ctx, service, method, path.Content)
 }
 
+func (m *ExternalServiceManager) receiveManyRequestsWithPayloadFromTable(ctx context.Context, service, method string, table *godog.Table) (context.Context, error) {
+	return m.receiveRequestWithTable(ctx, service, method, planner.UnlimitedTimes, table)
+}
+
+func (m *ExternalServiceManager) receiveCompressedRequest(ctx context.Context, name string) error {
+	return serverRequestPlannerFromContext(ctx).WithCompression(name)
+}
+
 func (m *ExternalServiceManager) respondWithPayload(ctx context.Context, payload string) error {
 	return serverRequestPlannerFromContext(ctx).Return(payload)
 }
@@ -174,6 +300,10 @@ func (m *ExternalServiceManager) respondWithPayloadFromFileDocString(ctx context
 	return m.respondWithPayloadFromFile(ctx, path.Content)
 }
 
+func (m *ExternalServiceManager) respondWithPayloadFromTable(ctx context.Context, table *godog.Table) error {
+	return serverRequestPlannerFromContext(ctx).ReturnTable(table)
+}
+
 func (m *ExternalServiceManager) respondWithError(ctx context.Context, codeValue string, message string) error {
 	code, err := toStatusCode(codeValue)
 	if err != nil {
@@ -199,94 +329,757 @@ func (m *ExternalServiceManager) respondWithErrorMessageFromDocString(ctx contex
 	return m.respondWithErrorMessage(ctx, message.Content)
 }
 
-func (m *ExternalServiceManager) resetExpectations() {
+func (m *ExternalServiceManager) respondInSequence(ctx context.Context, table *godog.Table) error {
+	responses, err := toSequencedResponses(table)
+	if err != nil {
+		return err
+	}
+
+	return serverRequestPlannerFromContext(ctx).ReturnInSequence(responses)
+}
+
+func (m *ExternalServiceManager) dropNextConnection(ctx context.Context, serviceID string) error {
+	return m.dropNextConnections(ctx, serviceID, 1)
+}
+
+func (m *ExternalServiceManager) dropNextConnections(ctx context.Context, serviceID string, times int) error {
+	sc, err := m.scope(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	sc.faults.DropConnections(times)
+
+	return nil
+}
+
+func (m *ExternalServiceManager) failRequests(ctx context.Context, serviceID string, percentage float64, codeValue string) error {
+	sc, err := m.scope(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	if percentage > 100 {
+		return fmt.Errorf("%w: %v%%", ErrInvalidPercentage, percentage)
+	}
+
+	code, err := toStatusCode(codeValue)
+	if err != nil {
+		return err
+	}
+
+	sc.faults.Fail(percentage/100, code)
+
+	return nil
+}
+
+func (m *ExternalServiceManager) addRandomLatency(ctx context.Context, serviceID, minValue, maxValue string) error {
+	sc, err := m.scope(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	minLatency, err := time.ParseDuration(minValue)
+	if err != nil {
+		return err
+	}
+
+	maxLatency, err := time.ParseDuration(maxValue)
+	if err != nil {
+		return err
+	}
+
+	if minLatency > maxLatency {
+		return fmt.Errorf("%w: %s is greater than %s", ErrInvalidLatency, minLatency, maxLatency)
+	}
+
+	sc.faults.AddLatency(minLatency, maxLatency)
+
+	return nil
+}
+
+func (m *ExternalServiceManager) setHealthStatus(ctx context.Context, serviceID, status string) error {
+	return m.setServiceHealthStatus(ctx, serviceID, "", status)
+}
+
+func (m *ExternalServiceManager) setServiceHealthStatus(ctx context.Context, serviceID, service, statusValue string) error {
+	srv, err := m.server(serviceID)
+	if err != nil {
+		return err
+	}
+
+	sc := srv.scope(scenarioFromContext(ctx))
+
+	if sc.health == nil {
+		//goland:noinspection GoErrorStringFormat
+		return fmt.Errorf(
+			"%w, did you forget to setup the grpc service %q with grpcsteps.WithHealthService()?",
+			ErrHealthServiceNotEnabled, serviceID,
+		)
+	}
+
+	status, err := toHealthStatus(statusValue)
+	if err != nil {
+		return err
+	}
+
+	sc.health.setStatus(service, status)
+
+	// The checks and the watches without the scenario header are answered by the default scope.
+	if sc != srv.serviceScope {
+		srv.health.setStatus(service, status)
+	}
+
+	return nil
+}
+
+func (m *ExternalServiceManager) setServiceState(ctx context.Context, serviceID, state string) error {
+	sc, err := m.scope(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	sc.state.Set(state)
+
+	return nil
+}
+
+func (m *ExternalServiceManager) assertServiceState(ctx context.Context, serviceID, expected string) error {
+	sc, err := m.scope(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	if actual := sc.state.Current(); actual != expected {
+		return fmt.Errorf("unexpected state of grpc service %q, got %q, want %q", serviceID, actual, expected) // nolint: goerr113
+	}
+
+	return nil
+}
+
+func (m *ExternalServiceManager) expectWhenInState(ctx context.Context, state string) error {
+	return serverRequestPlannerFromContext(ctx).WhenInState(state)
+}
+
+func (m *ExternalServiceManager) moveToState(ctx context.Context, state string) error {
+	return serverRequestPlannerFromContext(ctx).MoveToState(state)
+}
+
+func (m *ExternalServiceManager) assertReceivedInClientTrace(ctx context.Context, serviceID string) error {
+	sc, err := m.scope(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	span, err := clientRequestSpanContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return assertReceivedInTrace(serviceID, sc.received.All(), span.TraceID())
+}
+
+// assertReceivedWithClientHeader checks that the mocked service received every request of the scenario with the values
+// of the header of the last client request. With grpcsteps.WithTracing(), only the requests in the trace of the client
+// request are checked, wherever they are received.
+func (m *ExternalServiceManager) assertReceivedWithClientHeader(ctx context.Context, header, serviceID string) error {
+	sc, err := m.scope(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	r, err := sentClientRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The requests of the scenario are checked. If the client request is traced, they are the ones in its trace, even
+	// the ones that are sent after its response, so the scenario could send requests with other values.
+	received := sc.received.All()
+
+	if r.spanContext.IsValid() {
+		received = receivedInTrace(received, r.spanContext.TraceID())
+	}
+
+	return assertReceivedWithHeader(serviceID, received, header, r.header.Get(header))
+}
+
+func (m *ExternalServiceManager) assertReceivedWithDeadline(ctx context.Context, serviceID string) error {
+	sc, err := m.scope(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	return assertReceivedWithDeadline(serviceID, sc.received.All(), 0)
+}
+
+func (m *ExternalServiceManager) assertReceivedWithRemainingDeadline(ctx context.Context, serviceID, maxValue string) error {
+	sc, err := m.scope(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+
+	maxRemaining, err := time.ParseDuration(maxValue)
+	if err != nil {
+		return err
+	}
+
+	// A request could not have less than nothing left, the check would always pass.
+	if maxRemaining <= 0 {
+		return fmt.Errorf("%w: %q, a positive duration expected", ErrInvalidDeadline, maxValue)
+	}
+
+	return assertReceivedWithDeadline(serviceID, sc.received.All(), maxRemaining)
+}
+
+// afterScenario checks the expectations of the scenario, unless it already failed. If the scenario fails, the transcript
+// is reported and the suite pauses.
+func (m *ExternalServiceManager) afterScenario(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
+	defer m.endScenario(sc.Id)
+
+	var unmetErr error
+
+	if err == nil {
+		unmetErr = m.assertExpectationsWereMet(sc.Id)
+		err = unmetErr
+	}
+
+	if err == nil {
+		return ctx, nil
+	}
+
+	ctx = transcriptFromContext(ctx).reportFailure(ctx)
+
+	m.pauseOnFailure(sc.Name, err)
+
+	return ctx, unmetErr
+}
+
+func (m *ExternalServiceManager) startScenario(sc *godog.Scenario, t *transcript) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, srv := range m.servers {
-		srv.ResetExpectations()
+		srv.startScenario(sc, t)
 	}
 }
 
-// AddService starts a new service and returns the server address for client to connect.
+func (m *ExternalServiceManager) endScenario(id string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, srv := range m.servers {
+		srv.endScenario(id)
+	}
+}
+
+// AddService starts a new service and returns the server address for client to connect. If the manager uses the in-memory
+// transport, use WithExternalService() to connect to the service.
 func (m *ExternalServiceManager) AddService(id string, opts ...grpcmock.ServerOption) string {
-	m.servers[id] = newServer(opts...)
+	return m.AddServiceWithOptions(id, WithServerOptions(opts...))
+}
+
+// AddServiceWithOptions starts a new service with the options that grpcmock does not know about, like WithUpstream() or
+// WithHealthService(), and returns the server address for client to connect.
+//
+//	m.AddServiceWithOptions("item-service",
+//		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+//		grpcsteps.WithHealthService(),
+//	)
+func (m *ExternalServiceManager) AddServiceWithOptions(id string, opts ...ExternalServiceOption) string {
+	if m.reflection {
+		opts = append([]ExternalServiceOption{withReflection()}, opts...)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.inMemory {
+		l := bufconn.Listen(inMemoryBufferSize)
+		m.listeners[id] = l
+
+		opts = append([]ExternalServiceOption{WithServerOptions(grpcmock.WithListener(l))}, opts...)
+	}
+
+	m.servers[id] = newServer(id, m.randomSeed, opts...)
 
 	return m.servers[id].Address()
 }
 
+func (m *ExternalServiceManager) dial(ctx context.Context, id string) (net.Conn, error) {
+	m.mu.RLock()
+	l, ok := m.listeners[id]
+	m.mu.RUnlock()
+
+	if ok {
+		return l.DialContext(ctx)
+	}
+
+	srv, err := m.server(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return (&net.Dialer{}).DialContext(ctx, "tcp", srv.Address())
+}
+
+// AddServiceFromProto starts a new service that is defined in the proto file and returns the server address for client
+// to connect. See RegisterServiceFromProto().
+func (m *ExternalServiceManager) AddServiceFromProto(id, protoFile string, opts ...grpcmock.ServerOption) string {
+	return m.AddServiceWithOptions(id, RegisterServiceFromProto(protoFile), WithServerOptions(opts...))
+}
+
+// AddServiceFromDescriptorSet starts a new service that is defined in the file descriptor set and returns the server
+// address for client to connect. See RegisterServiceFromDescriptorSet().
+func (m *ExternalServiceManager) AddServiceFromDescriptorSet(id, file string, opts ...grpcmock.ServerOption) string {
+	return m.AddServiceWithOptions(id, RegisterServiceFromDescriptorSet(file), WithServerOptions(opts...))
+}
+
 // Close closes the server.
 func (m *ExternalServiceManager) Close() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, srv := range m.servers {
 		_ = srv.Close() // nolint: errcheck
 	}
 }
 
-func (m *ExternalServiceManager) assertExpectationsWereMet() error {
+// pauseOnFailure keeps the services up after a scenario fails, if the manager has WithPauseOnFailure().
+func (m *ExternalServiceManager) pauseOnFailure(scenario string, err error) {
+	if m.pause == nil {
+		return
+	}
+
+	m.pause(m.pauseMessage(scenario, err))
+}
+
+func (m *ExternalServiceManager) pauseMessage(scenario string, err error) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.servers))
+
+	for id := range m.servers {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	var sb strings.Builder
+
+	_, _ = fmt.Fprintf(&sb, "scenario %q failed: %s\n\nthe grpc services are still up:\n", scenario, err.Error())
+
+	for _, id := range ids {
+		if _, ok := m.listeners[id]; ok {
+			_, _ = fmt.Fprintf(&sb, "- %s: in memory\n", id)
+		} else {
+			_, _ = fmt.Fprintf(&sb, "- %s: %s\n", id, m.servers[id].Address())
+		}
+	}
+
+	sb.WriteString("\npress Enter to continue")
+
+	return sb.String()
+}
+
+func (m *ExternalServiceManager) assertExpectationsWereMet(scenarioID string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, srv := range m.servers {
-		if err := srv.ExpectationsWereMet(); err != nil {
+		if err := srv.scope(scenarioID).server.ExpectationsWereMet(); err != nil {
 			return err
 		}
+
+		if srv.recorder != nil {
+			if err := srv.recorder.Err(scenarioID); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
 // NewExternalServiceManager initiates a new external service manager for testing.
-func NewExternalServiceManager() *ExternalServiceManager {
-	return &ExternalServiceManager{
-		servers: make(map[string]*wrappedServer),
+func NewExternalServiceManager(opts ...ExternalServiceManagerOption) *ExternalServiceManager {
+	m := &ExternalServiceManager{
+		servers:    make(map[string]*wrappedServer),
+		listeners:  make(map[string]*bufconn.Listener),
+		randomSeed: time.Now().UnixNano(),
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	return m
+}
+
+// WithRandomSeed sets the seed of the injected faults, so they are the same in every run.
+func WithRandomSeed(seed int64) ExternalServiceManagerOption {
+	return func(m *ExternalServiceManager) {
+		m.randomSeed = seed
+	}
+}
+
+// WithInMemoryTransport makes the services listen in memory instead of on TCP ports. The clients connect to them with
+// WithExternalService().
+func WithInMemoryTransport() ExternalServiceManagerOption {
+	return func(m *ExternalServiceManager) {
+		m.inMemory = true
+	}
+}
+
+// WithReflection serves the server reflection services (grpc.reflection.v1 and grpc.reflection.v1alpha) on all the
+// services, so they could be inspected with tools like grpcurl. The reflection describes the registered services and the
+// services that are defined in proto files and descriptor sets.
+func WithReflection() ExternalServiceManagerOption {
+	return func(m *ExternalServiceManager) {
+		m.reflection = true
+	}
+}
+
+// WithServerOptions sets up the grpcmock server of the mocked service.
+func WithServerOptions(opts ...grpcmock.ServerOption) ExternalServiceOption {
+	return func(c *serviceConfig) {
+		c.serverOptions = append(c.serverOptions, opts...)
+	}
+}
+
+// WithPauseOnFailure keeps the services up when a scenario fails or does not meet the expectations, until Enter is
+// pressed or the process is interrupted, so they could be inspected while they still have the expectations of the
+// scenario. There is no pause if the standard input is not a terminal, for example in CI.
+func WithPauseOnFailure() ExternalServiceManagerOption {
+	return func(m *ExternalServiceManager) {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return
+		}
+
+		m.pause = pauseUntilResumed(os.Stdin, os.Stderr)
+	}
+}
+
+// pauseUntilResumed writes the message and waits for a line from the input or for an interrupt. There is one pause at a
+// time. The input is read by one goroutine for all the pauses, so a pause that is interrupted does not leave a reader
+// behind, and the lines are not lost between the pauses. Once the input is closed, there is no pause anymore.
+func pauseUntilResumed(in io.Reader, out io.Writer) func(message string) {
+	var (
+		mu    sync.Mutex
+		once  sync.Once
+		lines = make(chan struct{})
+	)
+
+	readLines := func() {
+		defer close(lines)
+
+		r := bufio.NewReader(in)
+
+		for {
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+
+			lines <- struct{}{}
+		}
+	}
+
+	return func(message string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		_, _ = fmt.Fprintln(out, message) // nolint: errcheck
+
+		once.Do(func() { go readLines() })
+
+		interrupted := make(chan os.Signal, 1)
+
+		signal.Notify(interrupted, os.Interrupt)
+		defer signal.Stop(interrupted)
+
+		select {
+		case <-lines:
+		case <-interrupted:
+		}
 	}
 }
 
 type wrappedServer struct {
 	*grpcmock.Server
+	*serviceScope
+
+	scopes     map[string]*serviceScope
+	fallbacks  []fallback
+	randomSeed int64
+
+	upstream   *upstream
+	recorder   *recorder
+	replayer   *replayer
+	reflection *reflectionService
+	dynamic    *dynamicServices
+
+	mu sync.Mutex
+}
+
+// startScenario gives the scenario its own expectations, state, faults and transcript.
+func (s *wrappedServer) startScenario(sc *godog.Scenario, t *transcript) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.scopes == nil {
+		s.scopes = make(map[string]*serviceScope)
+	}
+
+	s.scopes[sc.Id] = newServiceScope(s.Server, s.randomSeed, sc, s.fallbacks...)
+	s.scopes[sc.Id].transcript = t
+	s.scopes[sc.Id].received = &receivedRequests{}
+
+	if s.health != nil {
+		s.scopes[sc.Id].health = newHealthService(s.health.served)
+	}
+}
+
+func (s *wrappedServer) endScenario(id string) {
+	s.mu.Lock()
+	sc := s.scopes[id]
+	delete(s.scopes, id)
+	idle := len(s.scopes) == 0
+	s.mu.Unlock()
+
+	if sc != nil && sc.health != nil {
+		sc.health.shutdown()
+	}
+
+	// The default scope serves the requests that no running scenario could take, it is reset once no scenario runs. The
+	// grpcmock server is reset without the lock, because it plans the requests with the lock of the grpcmock server.
+	if idle {
+		s.ResetExpectations()
+	}
+
+	if s.replayer != nil {
+		s.replayer.endScenario(id)
+	}
+
+	if s.recorder != nil {
+		s.recorder.endScenario(id)
+	}
+}
+
+// ResetExpectations resets the expectations and the faults of the default scope, and brings it back to its initial
+// state. The scopes of the scenarios are dropped when the scenarios end.
+func (s *wrappedServer) ResetExpectations() {
+	s.Server.ResetExpectations()
+	s.state.Reset()
+	s.headers.Reset()
+	s.faults.Reset()
+
+	if s.health != nil {
+		s.health.reset()
+	}
+}
+
+// scope returns the scope of the scenario, or the default scope if the scenario is not running.
+func (s *wrappedServer) scope(id string) *serviceScope {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sc, ok := s.scopes[id]; ok {
+		return sc
+	}
+
+	return s.serviceScope
+}
+
+// requestScope returns the scope of the scenario that sends the request. A request without the scenario header belongs
+// to the only running scenario, if any.
+func (s *wrappedServer) requestScope(ctx context.Context) *serviceScope {
+	if id, ok := scenarioFromIncomingContext(ctx); ok {
+		return s.scope(id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.scopes) == 1 {
+		for _, sc := range s.scopes {
+			return sc
+		}
+	}
+
+	return s.serviceScope
+}
+
+// requestHealth returns the health service of the scenario that sends the request. The requests without the scenario
+// header are answered by the default scope, because the watches that the application under test opens on start outlive
+// the scenarios.
+func (s *wrappedServer) requestHealth(ctx context.Context) *healthService {
+	if id, ok := scenarioFromIncomingContext(ctx); ok {
+		return s.scope(id).health
+	}
+
+	return s.health
 }
 
-func (s *wrappedServer) expect(method string, times uint, payload *string) (expectation, error) {
+func (s *wrappedServer) requestFaults(ctx context.Context) *faultInjector {
+	return s.requestScope(ctx).faults
+}
+
+// messageTypes returns the types of the requests and the responses of a method, dynamic if the service is.
+func (s *wrappedServer) messageTypes(method string) (messageType, messageType, error) {
 	svc := grpcmock.FindServerMethod(s.Server, method)
 	if svc == nil {
-		return nil, fmt.Errorf("%w: %s", ErrGRPCMethodNotFound, method)
+		return messageType{}, messageType{}, fmt.Errorf("%w: %s", ErrGRPCMethodNotFound, method)
 	}
 
-	var expected expectation
+	if s.dynamic != nil {
+		if m, ok := s.dynamic.methods[method]; ok {
+			in, out := methodMessageTypes(svc.MethodType, m.input, m.output)
 
-	switch svc.MethodType {
-	case service.TypeUnary:
-		expected = &unaryExpectation{s.ExpectUnary(method)}
+			return in, out, nil
+		}
+	}
 
-	case service.TypeClientStream:
-		expected = &clientStreamExpectation{s.ExpectClientStream(method)}
+	return newMessageTypes(*svc)
+}
+
+func (s *wrappedServer) addFallback(f fallback) {
+	s.fallbacks = append(s.fallbacks, f)
+	s.planner.addFallback(f)
+}
 
-	case service.TypeServerStream:
-		expected = &serverStreamExpectation{s.ExpectServerStream(method)}
+// Close stops the service and closes the connection to the upstream, if any.
+func (s *wrappedServer) Close() error {
+	err := s.Server.Close()
 
-	case service.TypeBidirectionalStream:
-		return nil, fmt.Errorf("%w: %s %s", ErrGRPCMethodNotSupported, svc.MethodType, method)
+	if s.upstream != nil {
+		_ = s.upstream.Close() // nolint: errcheck
 	}
 
-	expected.Times(times)
+	return err
+}
+
+func newServer(id string, randomSeed int64, opts ...ExternalServiceOption) *wrappedServer {
+	cfg := &serviceConfig{}
 
-	if payload != nil {
-		expected.WithPayload(*payload)
+	for _, o := range opts {
+		o(cfg)
 	}
 
-	return expected, nil
-}
+	state := newServiceState()
+	headers := newHeaderMatcher()
+	faults := newFaultInjector(randomSeed)
+
+	srv := &wrappedServer{
+		serviceScope: &serviceScope{
+			planner: newStatefulPlanner(state, headers),
+			state:   state,
+			headers: headers,
+			faults:  faults,
+		},
+		randomSeed: randomSeed,
+	}
 
-func newServer(opts ...grpcmock.ServerOption) *wrappedServer {
-	return &wrappedServer{
-		Server: grpcmock.NewServer(opts...),
+	// The requests are recorded with their faults, the built-in services answer before the faults and the expectations.
+	// Only the requests that reach the mocked services are received, the health and reflection probes are not.
+	serverOpts := []grpcmock.ServerOption{grpcmock.WithPlanner(scenarioPlanner{server: srv})}
+	serverOpts = append(serverOpts, transcriptServerOptions(id, func() string { return srv.Address() }, srv.requestScope)...)
+	serverOpts = append(serverOpts, compressionServerOptions()...)
+	serverOpts = append(serverOpts, healthServerOptions(srv.requestHealth)...)
+	serverOpts = append(serverOpts, reflectionServerOptions(func() *reflectionService { return srv.reflection })...)
+	serverOpts = append(serverOpts, receivedServerOptions(srv.requestScope)...)
+	serverOpts = append(serverOpts, faultServerOptions(cfg.credentials, srv.requestFaults)...)
+
+	if cfg.reflection {
+		serverOpts = append(serverOpts,
+			grpcmock.RegisterService(reflectionv1.RegisterServerReflectionServer),
+			grpcmock.RegisterService(reflectionv1alpha.RegisterServerReflectionServer),
+		)
 	}
+
+	if cfg.health {
+		serverOpts = append(serverOpts, grpcmock.RegisterService(healthpb.RegisterHealthServer))
+	}
+
+	if cfg.dynamic != nil {
+		serverOpts = append(serverOpts, grpcmock.UnknownServiceHandler(cfg.dynamic.handleStream))
+	}
+
+	serverOpts = append(serverOpts, cfg.serverOptions...)
+	serverOpts = append(serverOpts, deferredHandlerServerOptions()...)
+
+	srv.Server = grpcmock.NewUnstartedServer(serverOpts...)
+	srv.serviceScope.server = srv.Server
+	srv.serviceScope.served = srv.Server
+
+	if cfg.replayDir != "" {
+		r, err := newReplayer(id, cfg.replayDir)
+		must.NotFail(err)
+
+		srv.replayer = r
+
+		srv.addFallback(r)
+	}
+
+	if cfg.dynamic != nil {
+		cfg.dynamic.planner = scenarioPlanner{server: srv}
+		cfg.dynamic.server = srv.Server
+		srv.dynamic = cfg.dynamic
+	}
+
+	if cfg.upstreamAddr != "" {
+		u, err := newUpstream(cfg.upstreamAddr, cfg.upstreamDialOptions...)
+		must.NotFail(err)
+
+		if cfg.recordDir != "" {
+			u.recorder, err = newRecorder(id, cfg.recordDir)
+			must.NotFail(err)
+		}
+
+		srv.upstream = u
+		srv.recorder = u.recorder
+
+		srv.addFallback(u)
+	}
+
+	if cfg.health {
+		srv.health = newHealthService(func(service string) bool {
+			return servesService(srv.Server, srv.dynamic, service)
+		})
+	}
+
+	if cfg.reflection {
+		srv.reflection = newReflectionService(srv.Server, srv.dynamic)
+	}
+
+	srv.Serve()
+
+	if cfg.dynamic != nil {
+		// The dynamic methods are registered after serving, so they are handled by the unknown service handler.
+		grpcmock.RegisterServiceFromMethods(cfg.dynamic.serviceMethods()...)(srv.Server)
+	}
+
+	return srv
 }
 
 type expectation interface {
 	WithPayload(in interface{})
 	WithHeader(key string, value interface{})
+	WithHeaderValues(key string, values []string)
+	WithCompression(name string)
 	Return(v interface{})
 	ReturnError(code codes.Code, msg string)
+	ReturnInSequence(responses []sequencedResponse)
 	Times(i uint)
+	WhenInState(state string)
+	MoveToState(state string)
 }
 
 type unaryExpectation struct {
 	grpcmock.UnaryExpectation
+
+	state   *serviceState
+	headers *headerMatcher
 }
 
 func (e *unaryExpectation) WithPayload(in interface{}) {
@@ -297,6 +1090,14 @@ func (e *unaryExpectation) WithHeader(key string, value interface{}) {
 	e.UnaryExpectation.WithHeader(key, value)
 }
 
+func (e *unaryExpectation) WithHeaderValues(key string, values []string) {
+	e.headers.expect(e.UnaryExpectation, key, values)
+}
+
+func (e *unaryExpectation) WithCompression(name string) {
+	e.headers.expectCompression(e.UnaryExpectation, name)
+}
+
 func (e *unaryExpectation) Return(v interface{}) {
 	e.UnaryExpectation.Return(v)
 }
@@ -305,12 +1106,27 @@ func (e *unaryExpectation) ReturnError(code codes.Code, msg string) {
 	e.UnaryExpectation.ReturnError(code, msg)
 }
 
+func (e *unaryExpectation) ReturnInSequence(responses []sequencedResponse) {
+	e.UnaryExpectation.Run(newResponseSequence(responses).handleUnary)
+}
+
 func (e *unaryExpectation) Times(i uint) {
 	e.UnaryExpectation.Times(i)
 }
 
+func (e *unaryExpectation) WhenInState(state string) {
+	e.state.whenInState(e.UnaryExpectation, state)
+}
+
+func (e *unaryExpectation) MoveToState(state string) {
+	e.state.moveToState(e.UnaryExpectation, state)
+}
+
 type clientStreamExpectation struct {
 	grpcmock.ClientStreamExpectation
+
+	state   *serviceState
+	headers *headerMatcher
 }
 
 func (e *clientStreamExpectation) WithPayload(in interface{}) {
@@ -321,6 +1137,14 @@ func (e *clientStreamExpectation) WithHeader(key string, value interface{}) {
 	e.ClientStreamExpectation.WithHeader(key, value)
 }
 
+func (e *clientStreamExpectation) WithHeaderValues(key string, values []string) {
+	e.headers.expect(e.ClientStreamExpectation, key, values)
+}
+
+func (e *clientStreamExpectation) WithCompression(name string) {
+	e.headers.expectCompression(e.ClientStreamExpectation, name)
+}
+
 func (e *clientStreamExpectation) Return(v interface{}) {
 	e.ClientStreamExpectation.Return(v)
 }
@@ -329,12 +1153,29 @@ func (e *clientStreamExpectation) ReturnError(code codes.Code, msg string) {
 	e.ClientStreamExpectation.ReturnError(code, msg)
 }
 
+func (e *clientStreamExpectation) ReturnInSequence(responses []sequencedResponse) {
+	e.ClientStreamExpectation.Run(newResponseSequence(responses).handleClientStream)
+}
+
 func (e *clientStreamExpectation) Times(i uint) {
 	e.ClientStreamExpectation.Times(i)
 }
 
+func (e *clientStreamExpectation) WhenInState(state string) {
+	e.state.whenInState(e.ClientStreamExpectation, state)
+}
+
+func (e *clientStreamExpectation) MoveToState(state string) {
+	e.state.moveToState(e.ClientStreamExpectation, state)
+}
+
 type serverStreamExpectation struct {
 	grpcmock.ServerStreamExpectation
+
+	state   *serviceState
+	headers *headerMatcher
+	// dynamic is true if the method is defined by descriptors, without generated code.
+	dynamic bool
 }
 
 func (e *serverStreamExpectation) WithPayload(in interface{}) {
@@ -345,14 +1186,42 @@ func (e *serverStreamExpectation) WithHeader(key string, value interface{}) {
 	e.ServerStreamExpectation.WithHeader(key, value)
 }
 
+func (e *serverStreamExpectation) WithHeaderValues(key string, values []string) {
+	e.headers.expect(e.ServerStreamExpectation, key, values)
+}
+
+func (e *serverStreamExpectation) WithCompression(name string) {
+	e.headers.expectCompression(e.ServerStreamExpectation, name)
+}
+
 func (e *serverStreamExpectation) Return(v interface{}) {
-	e.ServerStreamExpectation.Return(v)
+	payload, ok := v.(string)
+	if !ok || !e.dynamic {
+		e.ServerStreamExpectation.Return(v)
+
+		return
+	}
+
+	// grpcmock creates the messages from the method type, which does not work for the dynamic messages.
+	e.ReturnInSequence([]sequencedResponse{{code: codes.OK, payload: payload}})
 }
 
 func (e *serverStreamExpectation) ReturnError(code codes.Code, msg string) {
 	e.ServerStreamExpectation.ReturnError(code, msg)
 }
 
+func (e *serverStreamExpectation) ReturnInSequence(responses []sequencedResponse) {
+	e.ServerStreamExpectation.Run(newResponseSequence(responses).handleServerStream)
+}
+
 func (e *serverStreamExpectation) Times(i uint) {
 	e.ServerStreamExpectation.Times(i)
 }
+
+func (e *serverStreamExpectation) WhenInState(state string) {
+	e.state.whenInState(e.ServerStreamExpectation, state)
+}
+
+func (e *serverStreamExpectation) MoveToState(state string) {
+	e.state.moveToState(e.ServerStreamExpectation, state)
+}
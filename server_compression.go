@@ -0,0 +1,50 @@
+package grpcsteps
+
+import (
+	"context"
+
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/encoding/gzip" // Register the gzip compressor for the clients and the mocked services.
+)
+
+type compressionCtxKey struct{}
+
+// compressionServerOptions returns the options that put the compression of the requests in the context before they
+// reach the expectations. grpc does not put it in the metadata, and the metadata is what the client sent.
+func compressionServerOptions() []grpcmock.ServerOption {
+	return []grpcmock.ServerOption{
+		grpcmock.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(withCompression(ctx), req)
+		}),
+		grpcmock.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return handler(srv, compressedServerStream{ServerStream: ss, ctx: withCompression(ss.Context())})
+		}),
+	}
+}
+
+func withCompression(ctx context.Context) context.Context {
+	s, ok := grpc.ServerTransportStreamFromContext(ctx).(interface{ RecvCompress() string })
+	if !ok || s.RecvCompress() == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, compressionCtxKey{}, s.RecvCompress())
+}
+
+// compressionFromContext returns the compression of the request, or an empty string if it is not compressed.
+func compressionFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(compressionCtxKey{}).(string) // nolint: errcheck
+
+	return name
+}
+
+type compressedServerStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s compressedServerStream) Context() context.Context {
+	return s.ctx
+}
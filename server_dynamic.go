@@ -0,0 +1,390 @@
+package grpcsteps
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/bufbuild/protocompile"
+	"go.nhat.io/grpcmock"
+	xerrors "go.nhat.io/grpcmock/errors"
+	"go.nhat.io/grpcmock/must"
+	"go.nhat.io/grpcmock/planner"
+	"go.nhat.io/grpcmock/service"
+	"go.nhat.io/grpcmock/streamer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// RegisterServiceFromProto registers the services that are defined in the proto files, without generated code. The
+// requests and the responses are dynamic messages. The files and their imports are looked up from the working directory,
+// the well-known types are always available.
+//
+//	m.AddServiceWithOptions("item-service",
+//		grpcsteps.RegisterServiceFromProto("resources/protobuf/service.proto"),
+//	)
+func RegisterServiceFromProto(protoFiles ...string) ExternalServiceOption {
+	return func(c *serviceConfig) {
+		compiler := protocompile.Compiler{
+			Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{}),
+		}
+
+		files, err := compiler.Compile(context.Background(), protoFiles...)
+		must.NotFail(err)
+
+		fds := make([]protoreflect.FileDescriptor, 0, len(files))
+
+		for _, f := range files {
+			fds = append(fds, f)
+		}
+
+		registerDynamicServices(c, fds...)
+	}
+}
+
+// RegisterServiceFromDescriptorSet registers the services that are defined in a file descriptor set, without generated
+// code. The requests and the responses are dynamic messages. The file is a serialized FileDescriptorSet, for example:
+//
+//	protoc --include_imports --descriptor_set_out=service.protoset service.proto
+func RegisterServiceFromDescriptorSet(file string) ExternalServiceOption {
+	return func(c *serviceConfig) {
+		data, err := os.ReadFile(file) // nolint: gosec
+		must.NotFail(err)
+
+		var set descriptorpb.FileDescriptorSet
+
+		must.NotFail(proto.Unmarshal(data, &set))
+
+		files, err := protodesc.NewFiles(&set)
+		must.NotFail(err)
+
+		fds := make([]protoreflect.FileDescriptor, 0, files.NumFiles())
+
+		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			fds = append(fds, fd)
+
+			return true
+		})
+
+		registerDynamicServices(c, fds...)
+	}
+}
+
+func registerDynamicServices(c *serviceConfig, files ...protoreflect.FileDescriptor) {
+	if c.dynamic == nil {
+		c.dynamic = newDynamicServices()
+	}
+
+	for _, fd := range files {
+		c.dynamic.add(fd)
+	}
+}
+
+type dynamicMethod struct {
+	svc    service.Method
+	input  protoreflect.MessageDescriptor
+	output protoreflect.MessageDescriptor
+}
+
+func (m *dynamicMethod) newInput() *dynamicMessage {
+	return &dynamicMessage{Message: dynamicpb.NewMessage(m.input)}
+}
+
+func (m *dynamicMethod) newOutput() *dynamicMessage {
+	return &dynamicMessage{Message: dynamicpb.NewMessage(m.output)}
+}
+
+// dynamicServices serves the methods that are only known by their descriptors. grpcmock needs generated types to decode
+// the requests, so the methods are not served by grpcmock, but by an unknown service handler that does the same as
+// grpcmock with dynamic messages.
+type dynamicServices struct {
+	files    *protoregistry.Files
+	services map[string]protoreflect.ServiceDescriptor
+	methods  map[string]*dynamicMethod
+	planner  planner.Planner
+	// server keeps the handled requests, like grpcmock does for the generated services.
+	server *grpcmock.Server
+
+	mu sync.Mutex
+}
+
+func (d *dynamicServices) add(fd protoreflect.FileDescriptor) {
+	d.addFile(fd)
+
+	services := fd.Services()
+
+	for i := 0; i < services.Len(); i++ {
+		sd := services.Get(i)
+		methods := sd.Methods()
+
+		d.services[string(sd.FullName())] = sd
+
+		for j := 0; j < methods.Len(); j++ {
+			md := methods.Get(j)
+
+			m := &dynamicMethod{
+				svc: service.Method{
+					ServiceName: string(sd.FullName()),
+					MethodName:  string(md.Name()),
+					MethodType:  service.ToType(md.IsStreamingClient(), md.IsStreamingServer()),
+					Input:       &dynamicMessage{},
+					Output:      &dynamicMessage{},
+				},
+				input:  md.Input(),
+				output: md.Output(),
+			}
+
+			d.methods[m.svc.FullName()] = m
+		}
+	}
+}
+
+// addFile keeps the file and its imports, so their descriptors could be looked up by name.
+func (d *dynamicServices) addFile(fd protoreflect.FileDescriptor) {
+	if _, err := d.files.FindFileByPath(fd.Path()); err == nil {
+		return
+	}
+
+	_ = d.files.RegisterFile(fd) // nolint: errcheck
+
+	for i := 0; i < fd.Imports().Len(); i++ {
+		d.addFile(fd.Imports().Get(i).FileDescriptor)
+	}
+}
+
+func (d *dynamicServices) hasService(name string) bool {
+	_, ok := d.services[name]
+
+	return ok
+}
+
+// serviceMethods returns the methods, to be registered to grpcmock after the server starts serving, so the expectations
+// could be set.
+func (d *dynamicServices) serviceMethods() []service.Method {
+	result := make([]service.Method, 0, len(d.methods))
+
+	for _, m := range d.methods {
+		result = append(result, m.svc)
+	}
+
+	return result
+}
+
+func (d *dynamicServices) handleStream(_ interface{}, s grpc.ServerStream) error {
+	name, _ := grpc.MethodFromServerStream(s)
+
+	m, ok := d.methods[name]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", name)
+	}
+
+	s = &dynamicStream{ServerStream: s, method: m}
+	msgType := reflect.TypeOf(dynamicMessage{})
+
+	var in, out interface{}
+
+	switch m.svc.MethodType {
+	case service.TypeUnary:
+		in = m.newInput()
+		if err := s.RecvMsg(in); err != nil {
+			return xerrors.StatusError(err)
+		}
+
+		out = m.newOutput()
+
+		if err := d.handle(s.Context(), m.svc, in, out); err != nil {
+			return err
+		}
+
+		return s.SendMsg(out)
+
+	case service.TypeServerStream:
+		in = m.newInput()
+		if err := s.RecvMsg(in); err != nil {
+			return xerrors.StatusError(err)
+		}
+
+		out = streamer.NewServerStreamer(s, msgType)
+
+	case service.TypeClientStream:
+		in = streamer.NewClientStreamer(s, msgType, msgType)
+		out = m.newOutput()
+
+	default:
+		in = streamer.NewBidirectionalStreamer(s, msgType, msgType)
+		out = in
+	}
+
+	return d.handle(s.Context(), m.svc, in, out)
+}
+
+// handle picks the expectation with the lock, and handles the request without it, so a slow request does not block the
+// others.
+func (d *dynamicServices) handle(ctx context.Context, svc service.Method, in interface{}, out interface{}) error {
+	expected, err := d.plan(ctx, svc, in)
+	if err != nil {
+		return err
+	}
+
+	// The unary response is sent once it is handled, so the handling is not deferred.
+	if e, ok := expected.(deferredExpectation); ok {
+		expected = e.Expectation
+	}
+
+	h, _ := expected.(interface { //nolint: errcheck
+		Handle(ctx context.Context, in interface{}, out interface{}) error
+	})
+
+	return h.Handle(ctx, in, out)
+}
+
+func (d *dynamicServices) plan(ctx context.Context, svc service.Method, in interface{}) (planner.Expectation, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.planner.IsEmpty() {
+		return nil, xerrors.StatusError(planner.UnexpectedRequestError(svc, in))
+	}
+
+	expected, err := d.planner.Plan(ctx, svc, in)
+	if err != nil {
+		return nil, xerrors.StatusError(err)
+	}
+
+	// Log the request.
+	expected.Fulfilled()
+
+	d.server.Requests = append(d.server.Requests, expected)
+
+	return expected, nil
+}
+
+func newDynamicServices() *dynamicServices {
+	return &dynamicServices{
+		files:    &protoregistry.Files{},
+		services: make(map[string]protoreflect.ServiceDescriptor),
+		methods:  make(map[string]*dynamicMethod),
+	}
+}
+
+// dynamicStream gives the messages that grpcmock creates from the method types their descriptors.
+type dynamicStream struct {
+	grpc.ServerStream
+
+	method *dynamicMethod
+}
+
+func (s *dynamicStream) RecvMsg(m interface{}) error {
+	if msg, ok := m.(*dynamicMessage); ok && msg.Message == nil {
+		msg.Message = dynamicpb.NewMessage(s.method.input)
+	}
+
+	return s.ServerStream.RecvMsg(m)
+}
+
+// newOutputMessage creates a new output message of a stream, which is dynamic if the service is.
+func newOutputMessage(s grpc.ServerStream, t reflect.Type) interface{} {
+	switch s := s.(type) {
+	case *dynamicStream:
+		return s.method.newOutput()
+
+	case *streamer.ServerStreamer:
+		return newOutputMessage(s.ServerStream, t)
+
+	case *streamer.BidirectionalStreamer:
+		return newOutputMessage(s.ServerStream, t)
+	}
+
+	return reflect.New(t).Interface()
+}
+
+var _ proto.Message = (*dynamicMessage)(nil)
+
+// dynamicMessage is a dynamic message that has its own type, so grpcmock could create it from the method types.
+type dynamicMessage struct {
+	*dynamicpb.Message
+}
+
+func (m *dynamicMessage) ProtoReflect() protoreflect.Message {
+	return dynamicReflectMessage{Message: m.Message}
+}
+
+// MarshalJSON marshals the message like encoding/json marshals a generated message, so the payload matchers work the same
+// way for both.
+func (m *dynamicMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dynamicMessageValue(m.Message))
+}
+
+// dynamicReflectMessage keeps the clones of a dynamicMessage a dynamicMessage.
+type dynamicReflectMessage struct {
+	*dynamicpb.Message
+}
+
+func (m dynamicReflectMessage) New() protoreflect.Message {
+	return dynamicReflectMessage{Message: m.Message.New().(*dynamicpb.Message)} // nolint: errcheck
+}
+
+func (m dynamicReflectMessage) Interface() protoreflect.ProtoMessage {
+	return &dynamicMessage{Message: m.Message}
+}
+
+func dynamicMessageValue(m protoreflect.Message) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		result[string(fd.Name())] = dynamicFieldValue(fd, v)
+
+		return true
+	})
+
+	return result
+}
+
+func dynamicFieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		result := make([]interface{}, 0, list.Len())
+
+		for i := 0; i < list.Len(); i++ {
+			result = append(result, dynamicSingularValue(fd, list.Get(i)))
+		}
+
+		return result
+
+	case fd.IsMap():
+		result := make(map[string]interface{})
+
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			result[k.String()] = dynamicSingularValue(fd.MapValue(), v)
+
+			return true
+		})
+
+		return result
+	}
+
+	return dynamicSingularValue(fd, v)
+}
+
+func dynamicSingularValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	// nolint: exhaustive
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return dynamicMessageValue(v.Message())
+
+	case protoreflect.EnumKind:
+		return v.Enum()
+	}
+
+	return v.Interface()
+}
@@ -0,0 +1,144 @@
+package grpcsteps
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+func TestDynamicMessage_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		message  proto.Message
+	}{
+		{
+			scenario: "empty",
+			message:  &grpctest.Item{},
+		},
+		{
+			scenario: "int64",
+			message:  &grpctest.CreateItemsResponse{NumItems: 42},
+		},
+		{
+			scenario: "nested message",
+			message: &grpctest.Item{
+				Id:         42,
+				Name:       "Item #42",
+				CreateTime: &timestamppb.Timestamp{Seconds: 1234567890, Nanos: 42},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := proto.Marshal(tc.message)
+			require.NoError(t, err)
+
+			msg := &dynamicMessage{Message: dynamicpb.NewMessage(tc.message.ProtoReflect().Descriptor())}
+
+			require.NoError(t, proto.Unmarshal(data, msg))
+
+			expected, err := json.Marshal(tc.message)
+			require.NoError(t, err)
+
+			actual, err := json.Marshal(msg)
+			require.NoError(t, err)
+
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestDynamicMessage_Clone(t *testing.T) {
+	t.Parallel()
+
+	msg := &dynamicMessage{Message: dynamicpb.NewMessage((&grpctest.Item{}).ProtoReflect().Descriptor())}
+
+	assert.IsType(t, &dynamicMessage{}, proto.Clone(msg))
+}
+
+func TestRegisterServiceFromProto_FileNotFound(t *testing.T) {
+	t.Parallel()
+
+	assert.Panics(t, func() {
+		RegisterServiceFromProto("resources/protobuf/not-found.proto")(&serviceConfig{})
+	})
+}
+
+func TestDynamicServices_Requests(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer("item-service", 42, RegisterServiceFromProto("resources/protobuf/service.proto"))
+	defer srv.Close() // nolint: errcheck
+
+	expected, err := srv.expect("/grpctest.ItemService/GetItem", 1, nil)
+	require.NoError(t, err)
+
+	expected.Return(`{"id": 42}`)
+
+	conn, err := grpc.Dial(srv.Address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	defer conn.Close() // nolint: errcheck
+
+	item, err := grpctest.NewItemServiceClient(conn).GetItem(context.Background(), &grpctest.GetItemRequest{Id: 42})
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(42), item.GetId())
+
+	require.Len(t, srv.Requests, 1)
+	assert.Equal(t, "/grpctest.ItemService/GetItem", srv.Requests[0].ServiceMethod().FullName())
+}
+
+func TestServiceScope_ExpectServerStream(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		option   ExternalServiceOption
+		expected bool
+	}{
+		{
+			scenario: "generated",
+			option:   WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+		},
+		{
+			scenario: "dynamic",
+			option:   RegisterServiceFromProto("resources/protobuf/service.proto"),
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			srv := newServer("item-service", 42, tc.option)
+			defer srv.Close() // nolint: errcheck
+
+			expected, err := srv.expect("/grpctest.ItemService/ListItems", 1, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, expected.(*serverStreamExpectation).dynamic)
+		})
+	}
+}
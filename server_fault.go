@@ -0,0 +1,217 @@
+package grpcsteps
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// faultInjector injects faults into the requests before they reach the expectations.
+type faultInjector struct {
+	seed   int64
+	random *rand.Rand
+
+	drops      int
+	failRate   float64
+	failCode   codes.Code
+	minLatency time.Duration
+	maxLatency time.Duration
+
+	mu sync.Mutex
+}
+
+func (f *faultInjector) DropConnections(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.drops += n
+}
+
+func (f *faultInjector) Fail(rate float64, code codes.Code) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failRate = rate
+	f.failCode = code
+}
+
+func (f *faultInjector) AddLatency(minLatency, maxLatency time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.minLatency = minLatency
+	f.maxLatency = maxLatency
+}
+
+// Reset removes all the faults and reseeds the random generator, so every scenario sees the same faults.
+func (f *faultInjector) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.random = rand.New(rand.NewSource(f.seed)) // nolint: gosec
+	f.drops = 0
+	f.failRate = 0
+	f.failCode = codes.OK
+	f.minLatency = 0
+	f.maxLatency = 0
+}
+
+// plan decides the faults of a request.
+func (f *faultInjector) plan() (drop bool, latency time.Duration, code codes.Code) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.drops > 0 {
+		f.drops--
+
+		return true, 0, codes.Unavailable
+	}
+
+	if f.maxLatency > 0 {
+		latency = f.minLatency
+
+		if d := f.maxLatency - f.minLatency; d > 0 {
+			latency += time.Duration(f.random.Int63n(int64(d) + 1))
+		}
+	}
+
+	if f.failRate > 0 && f.random.Float64() < f.failRate {
+		code = f.failCode
+	}
+
+	return false, latency, code
+}
+
+func (f *faultInjector) inject(ctx context.Context) error {
+	drop, latency, code := f.plan()
+
+	if drop {
+		conn, ok := droppableConn(ctx)
+		if !ok {
+			return status.Error(codes.Internal, "could not drop the connection, use grpcsteps.WithServerCredentials() instead of grpcmock.Creds()")
+		}
+
+		_ = conn.Close() // nolint: errcheck
+
+		return status.Error(codes.Unavailable, "connection dropped")
+	}
+
+	if latency > 0 {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+
+		case <-time.After(latency):
+		}
+	}
+
+	if code != codes.OK {
+		return status.Error(code, "fault injected")
+	}
+
+	return nil
+}
+
+func (f *faultInjector) unaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := f.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (f *faultInjector) streamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := f.inject(ss.Context()); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+// WithServerCredentials sets the transport credentials of the mocked service, insecure if it is not set. Use it instead of
+// grpcmock.Creds(), so the service could still drop the connections.
+//
+//	m.AddServiceWithOptions("item-service",
+//		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+//		grpcsteps.WithServerCredentials(credentials.NewTLS(tlsConfig)),
+//	)
+func WithServerCredentials(creds credentials.TransportCredentials) ExternalServiceOption {
+	return func(c *serviceConfig) {
+		c.credentials = creds
+	}
+}
+
+// faultServerOptions returns the options that let the injectors intercept the requests and drop the connections of the
+// credentials. The injector of a request is looked up by its context.
+func faultServerOptions(creds credentials.TransportCredentials, injector func(ctx context.Context) *faultInjector) []grpcmock.ServerOption {
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+
+	return []grpcmock.ServerOption{
+		grpcmock.Creds(droppableConnCredentials{TransportCredentials: creds}),
+		grpcmock.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return injector(ctx).unaryInterceptor(ctx, req, info, handler)
+		}),
+		grpcmock.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return injector(ss.Context()).streamInterceptor(srv, ss, info, handler)
+		}),
+	}
+}
+
+func newFaultInjector(seed int64) *faultInjector {
+	f := &faultInjector{seed: seed}
+
+	f.Reset()
+
+	return f
+}
+
+// droppableConnCredentials keeps the connection in the auth info, so the fault injector could close it.
+type droppableConnCredentials struct {
+	credentials.TransportCredentials
+}
+
+func (c droppableConnCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, info, err := c.TransportCredentials.ServerHandshake(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conn, droppableConnInfo{AuthInfo: info, conn: conn}, nil
+}
+
+func (c droppableConnCredentials) Clone() credentials.TransportCredentials {
+	return droppableConnCredentials{TransportCredentials: c.TransportCredentials.Clone()}
+}
+
+type droppableConnInfo struct {
+	credentials.AuthInfo
+
+	conn net.Conn
+}
+
+// droppableConn returns the connection of the request, if the credentials of the service keep it.
+func droppableConn(ctx context.Context) (net.Conn, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	info, ok := p.AuthInfo.(droppableConnInfo)
+	if !ok {
+		return nil, false
+	}
+
+	return info.conn, true
+}
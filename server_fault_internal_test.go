@@ -0,0 +1,150 @@
+package grpcsteps
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+func TestFaultInjector_SameSeedSameFaults(t *testing.T) {
+	t.Parallel()
+
+	run := func(f *faultInjector) []codes.Code {
+		f.Fail(.5, codes.Unavailable)
+		f.AddLatency(10*time.Millisecond, 20*time.Millisecond)
+
+		result := make([]codes.Code, 0, 20)
+
+		for i := 0; i < 20; i++ {
+			_, latency, code := f.plan()
+
+			assert.GreaterOrEqual(t, latency, 10*time.Millisecond)
+			assert.LessOrEqual(t, latency, 20*time.Millisecond)
+
+			result = append(result, code)
+		}
+
+		return result
+	}
+
+	f := newFaultInjector(42)
+	expected := run(f)
+
+	assert.Contains(t, expected, codes.OK)
+	assert.Contains(t, expected, codes.Unavailable)
+
+	// Same seed, same faults.
+	assert.Equal(t, expected, run(newFaultInjector(42)))
+
+	// Reset reseeds the random generator.
+	f.Reset()
+
+	assert.Equal(t, expected, run(f))
+}
+
+func TestFaultInjector_DropConnections(t *testing.T) {
+	t.Parallel()
+
+	f := newFaultInjector(42)
+
+	f.DropConnections(2)
+
+	for i := 0; i < 2; i++ {
+		drop, _, _ := f.plan()
+
+		assert.True(t, drop)
+	}
+
+	drop, _, _ := f.plan()
+
+	assert.False(t, drop)
+}
+
+func TestExternalServiceManager_FailRequests_Error(t *testing.T) {
+	t.Parallel()
+
+	m := NewExternalServiceManager()
+	m.servers["item-service"] = &wrappedServer{serviceScope: &serviceScope{faults: newFaultInjector(42)}}
+
+	assert.EqualError(t, m.failRequests(context.Background(), "not-found", 10, "Unavailable"),
+		`grpc service not found, did you forget to setup the grpc service "not-found"?`)
+	assert.EqualError(t, m.failRequests(context.Background(), "item-service", 101, "Unavailable"), `invalid percentage: 101%`)
+	assert.EqualError(t, m.failRequests(context.Background(), "item-service", 10, "not a code"), `invalid code: "\"NOT A CODE\""`)
+}
+
+func TestExternalServiceManager_AddRandomLatency_Error(t *testing.T) {
+	t.Parallel()
+
+	m := NewExternalServiceManager()
+	m.servers["item-service"] = &wrappedServer{serviceScope: &serviceScope{faults: newFaultInjector(42)}}
+
+	assert.EqualError(t, m.addRandomLatency(context.Background(), "item-service", "1", "2s"), `time: missing unit in duration "1"`)
+	assert.EqualError(t, m.addRandomLatency(context.Background(), "item-service", "1s", "2"), `time: missing unit in duration "2"`)
+	assert.EqualError(t, m.addRandomLatency(context.Background(), "item-service", "2s", "1s"), `invalid latency: 2s is greater than 1s`)
+}
+
+func TestFaultInjector_DropConnections_UnknownCredentials(t *testing.T) {
+	t.Parallel()
+
+	f := newFaultInjector(42)
+
+	f.DropConnections(2)
+
+	expected := "rpc error: code = Internal desc = could not drop the connection, use grpcsteps.WithServerCredentials() instead of grpcmock.Creds()"
+
+	assert.EqualError(t, f.inject(context.Background()), expected)
+	assert.EqualError(t, f.inject(peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})), expected)
+}
+
+// handshakeCounter counts the handshakes of the credentials that it wraps.
+type handshakeCounter struct {
+	credentials.TransportCredentials
+
+	handshakes *int64
+}
+
+func (c handshakeCounter) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	atomic.AddInt64(c.handshakes, 1)
+
+	return c.TransportCredentials.ServerHandshake(conn)
+}
+
+func TestServer_DropConnections_WithServerCredentials(t *testing.T) {
+	t.Parallel()
+
+	var handshakes int64
+
+	l := bufconn.Listen(inMemoryBufferSize)
+
+	srv := newServer("item-service", 42,
+		WithServerOptions(
+			grpcmock.WithListener(l),
+			grpcmock.RegisterService(grpctest.RegisterItemServiceServer),
+		),
+		WithServerCredentials(handshakeCounter{TransportCredentials: insecure.NewCredentials(), handshakes: &handshakes}),
+	)
+
+	t.Cleanup(func() {
+		_ = srv.Close() // nolint: errcheck
+	})
+
+	srv.faults.DropConnections(1)
+
+	_, err := grpctest.NewItemServiceClient(dialInMemory(t, l)).GetItem(context.Background(), &grpctest.GetItemRequest{Id: 42})
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&handshakes))
+}
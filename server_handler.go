@@ -0,0 +1,88 @@
+package grpcsteps
+
+import (
+	"context"
+
+	"go.nhat.io/grpcmock"
+	"go.nhat.io/grpcmock/planner"
+	"google.golang.org/grpc"
+)
+
+type deferredHandlerCtxKey struct{}
+
+// deferredHandler is the handling of a request that is deferred until the expectation is picked. grpcmock handles the
+// requests with the lock of the server, so one slow upstream or long stream would block every other request, including
+// the ones of the other scenarios.
+type deferredHandler struct {
+	handle func() error
+}
+
+func (h *deferredHandler) run() error {
+	if h.handle == nil {
+		return nil
+	}
+
+	return h.handle()
+}
+
+// deferredExpectation defers its handling to the deferredHandler in context, if any.
+type deferredExpectation struct {
+	planner.Expectation
+}
+
+func (e deferredExpectation) Handle(ctx context.Context, in interface{}, out interface{}) error {
+	h, _ := e.Expectation.(interface { //nolint: errcheck
+		Handle(ctx context.Context, in interface{}, out interface{}) error
+	})
+
+	if d, ok := ctx.Value(deferredHandlerCtxKey{}).(*deferredHandler); ok {
+		d.handle = func() error {
+			return h.Handle(ctx, in, out)
+		}
+
+		return nil
+	}
+
+	return h.Handle(ctx, in, out)
+}
+
+// deferredHandlerServerOptions returns the options that handle the requests once the handler of grpcmock returns, and so
+// once its lock is released. They are the innermost interceptors, the response of a unary request is the output that the
+// deferred handling fills.
+func deferredHandlerServerOptions() []grpcmock.ServerOption {
+	return []grpcmock.ServerOption{
+		grpcmock.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			d := &deferredHandler{}
+
+			resp, err := handler(context.WithValue(ctx, deferredHandlerCtxKey{}, d), req)
+			if err != nil {
+				return resp, err
+			}
+
+			if err := d.run(); err != nil {
+				return nil, err
+			}
+
+			return resp, nil
+		}),
+		grpcmock.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			d := &deferredHandler{}
+
+			if err := handler(srv, deferredServerStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), deferredHandlerCtxKey{}, d)}); err != nil {
+				return err
+			}
+
+			return d.run()
+		}),
+	}
+}
+
+type deferredServerStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s deferredServerStream) Context() context.Context {
+	return s.ctx
+}
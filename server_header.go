@@ -0,0 +1,85 @@
+package grpcsteps
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"go.nhat.io/grpcmock/planner"
+	"go.nhat.io/grpcmock/service"
+	"google.golang.org/grpc/metadata"
+)
+
+// headerMatcher keeps the exact expected values of the headers, and the expected compressions. grpcmock only matches the
+// first value of a header, and the compression is not in the metadata.
+type headerMatcher struct {
+	values       map[interface{}]metadata.MD
+	compressions map[interface{}]string
+
+	mu sync.Mutex
+}
+
+func (h *headerMatcher) expect(expected interface{}, header string, values []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	md, ok := h.values[expected]
+	if !ok {
+		md = metadata.MD{}
+		h.values[expected] = md
+	}
+
+	md.Set(header, values...)
+}
+
+func (h *headerMatcher) expectCompression(expected interface{}, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.compressions[expected] = name
+}
+
+// match matches the headers and the compression of the request in context with the ones that the expectation expects,
+// if any.
+func (h *headerMatcher) match(ctx context.Context, expected planner.Expectation, req service.Method, in interface{}) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if name, ok := h.compressions[expected]; ok {
+		if actual := compressionFromContext(ctx); actual != name {
+			return planner.NewError(ctx, expected, req, in, "compression %q expected, %q received", name, actual)
+		}
+	}
+
+	md, ok := h.values[expected]
+	if !ok {
+		return nil
+	}
+
+	incoming, _ := metadata.FromIncomingContext(ctx)
+
+	for header, values := range md {
+		if actual := incoming.Get(header); !reflect.DeepEqual(values, actual) {
+			return planner.NewError(ctx, expected, req, in, "header %q with values %q expected, %q received", header, values, actual)
+		}
+	}
+
+	return nil
+}
+
+// Reset forgets the expected headers and compressions, like the planner forgets the expectations.
+func (h *headerMatcher) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.values = make(map[interface{}]metadata.MD)
+	h.compressions = make(map[interface{}]string)
+}
+
+func newHeaderMatcher() *headerMatcher {
+	h := &headerMatcher{}
+
+	h.Reset()
+
+	return h
+}
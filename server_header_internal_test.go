@@ -0,0 +1,37 @@
+package grpcsteps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.nhat.io/grpcmock"
+	"go.nhat.io/grpcmock/planner"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+func TestHeaderMatcher_Reset(t *testing.T) {
+	t.Parallel()
+
+	srv := grpcmock.NewUnstartedServer(grpcmock.RegisterService(grpctest.RegisterItemServiceServer))
+	expected := srv.ExpectUnary("/grpctest.ItemService/GetItem").(planner.Expectation) // nolint: errcheck
+	svc := *grpcmock.FindServerMethod(srv, "/grpctest.ItemService/GetItem")
+	in := &grpctest.GetItemRequest{Id: 42}
+
+	h := newHeaderMatcher()
+
+	h.expect(expected, "tag", []string{"foo"})
+	h.expectCompression(expected, "gzip")
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tag", "bar"))
+
+	assert.Error(t, h.match(ctx, expected, svc, in))
+
+	h.Reset()
+
+	assert.NoError(t, h.match(ctx, expected, svc, in))
+	assert.Empty(t, h.values)
+	assert.Empty(t, h.compressions)
+}
@@ -0,0 +1,185 @@
+package grpcsteps
+
+import (
+	"context"
+	"sync"
+
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// WithHealthService serves the standard health service (grpc.health.v1.Health) on the mocked service. The server and
+// its services are SERVING until a scenario changes them, the watchers are notified of every change. Every scenario has its
+// own statuses, they are dropped when the scenario ends. The checks and the watches without the scenario header, like the
+// ones that the application under test sends on start, see the statuses of every scenario until no scenario runs.
+//
+//	m.AddServiceWithOptions("item-service",
+//		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+//		grpcsteps.WithHealthService(),
+//	)
+func WithHealthService() ExternalServiceOption {
+	return func(c *serviceConfig) {
+		c.health = true
+	}
+}
+
+// healthService answers the health checks of a mocked service in a scope, so every scenario has its own statuses.
+type healthService struct {
+	*health.Server
+
+	served  func(service string) bool
+	changed map[string]struct{}
+	done    chan struct{}
+
+	mu sync.Mutex
+}
+
+func (h *healthService) setStatus(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	h.mu.Lock()
+	h.changed[service] = struct{}{}
+	h.mu.Unlock()
+
+	h.Server.SetServingStatus(service, status)
+}
+
+// reset brings the changed statuses back to SERVING, the watchers are notified.
+func (h *healthService) reset() {
+	h.mu.Lock()
+	changed := h.changed
+	h.changed = make(map[string]struct{})
+	h.mu.Unlock()
+
+	for service := range changed {
+		h.Server.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+}
+
+// shutdown makes the services NOT_SERVING and ends the watches.
+func (h *healthService) shutdown() {
+	h.Server.Shutdown()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	select {
+	case <-h.done:
+	default:
+		close(h.done)
+	}
+}
+
+// knowService makes the service SERVING if the mocked service serves it and its status is not set yet.
+func (h *healthService) knowService(ctx context.Context, service string) {
+	if _, err := h.Server.Check(ctx, &healthpb.HealthCheckRequest{Service: service}); err == nil || !h.served(service) {
+		return
+	}
+
+	h.Server.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+}
+
+func (h *healthService) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if info.FullMethod != healthpb.Health_Check_FullMethodName {
+		return handler(ctx, req)
+	}
+
+	in := req.(*healthpb.HealthCheckRequest) // nolint: errcheck
+
+	h.knowService(ctx, in.GetService())
+
+	return h.Server.Check(ctx, in)
+}
+
+func (h *healthService) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if info.FullMethod != healthpb.Health_Watch_FullMethodName {
+		return handler(srv, ss)
+	}
+
+	in := &healthpb.HealthCheckRequest{}
+
+	if err := ss.RecvMsg(in); err != nil {
+		return err
+	}
+
+	h.knowService(ss.Context(), in.GetService())
+
+	ctx, cancel := context.WithCancel(ss.Context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-h.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return h.Server.Watch(in, healthWatchServer{ServerStream: ss, ctx: ctx})
+}
+
+func newHealthService(served func(service string) bool) *healthService {
+	return &healthService{
+		Server:  health.NewServer(),
+		served:  served,
+		changed: make(map[string]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// healthServerOptions returns the options that let the health service answer the health checks before they reach the
+// faults and the expectations. The health service of the scope of the request is looked up when the request comes, there
+// is none unless the mocked service has WithHealthService().
+func healthServerOptions(h func(ctx context.Context) *healthService) []grpcmock.ServerOption {
+	return []grpcmock.ServerOption{
+		grpcmock.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if h := h(ctx); h != nil {
+				return h.unaryInterceptor(ctx, req, info, handler)
+			}
+
+			return handler(ctx, req)
+		}),
+		grpcmock.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if h := h(ss.Context()); h != nil {
+				return h.streamInterceptor(srv, ss, info, handler)
+			}
+
+			return handler(srv, ss)
+		}),
+	}
+}
+
+type healthWatchServer struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s healthWatchServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s healthWatchServer) Send(m *healthpb.HealthCheckResponse) error {
+	return s.ServerStream.SendMsg(m)
+}
+
+// servesService tells whether the mocked service serves the service.
+func servesService(srv *grpcmock.Server, dynamic *dynamicServices, name string) bool {
+	if dynamic != nil && dynamic.hasService(name) {
+		return true
+	}
+
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return false
+	}
+
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok || sd.Methods().Len() == 0 {
+		return false
+	}
+
+	return grpcmock.FindServerMethod(srv, name+"/"+string(sd.Methods().Get(0).Name())) != nil
+}
@@ -0,0 +1,190 @@
+package grpcsteps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cucumber/godog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+func TestHealthService_Watch(t *testing.T) {
+	t.Parallel()
+
+	l := bufconn.Listen(inMemoryBufferSize)
+
+	srv := newServer("item-service", 42,
+		WithServerOptions(
+			grpcmock.WithListener(l),
+			grpcmock.RegisterService(grpctest.RegisterItemServiceServer),
+		),
+		WithHealthService(),
+	)
+
+	t.Cleanup(func() {
+		_ = srv.Close() // nolint: errcheck
+	})
+
+	conn := dialInMemory(t, l)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{Service: "grpctest.ItemService"})
+	require.NoError(t, err)
+
+	recv := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+
+		return resp.GetStatus()
+	}
+
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, recv())
+
+	srv.health.setStatus("grpctest.ItemService", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, recv())
+}
+
+func TestHealthService_Scenario(t *testing.T) {
+	t.Parallel()
+
+	l := bufconn.Listen(inMemoryBufferSize)
+
+	srv := newServer("item-service", 42,
+		WithServerOptions(
+			grpcmock.WithListener(l),
+			grpcmock.RegisterService(grpctest.RegisterItemServiceServer),
+		),
+		WithHealthService(),
+	)
+
+	t.Cleanup(func() {
+		_ = srv.Close() // nolint: errcheck
+	})
+
+	client := healthpb.NewHealthClient(dialInMemory(t, l))
+
+	check := func(scenarioID string) healthpb.HealthCheckResponse_ServingStatus {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), ScenarioHeader, scenarioID)
+
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		return resp.GetStatus()
+	}
+
+	srv.startScenario(&godog.Scenario{Id: "first"}, nil)
+	srv.startScenario(&godog.Scenario{Id: "second"}, nil)
+
+	srv.scope("first").health.setStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	// The scenarios do not see the statuses of each other.
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check("first"))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check("second"))
+
+	srv.endScenario("first")
+
+	srv.startScenario(&godog.Scenario{Id: "first"}, nil)
+
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check("first"))
+}
+
+func TestExternalServiceManager_SetHealthStatus_WatchBeforeScenario(t *testing.T) {
+	t.Parallel()
+
+	l := bufconn.Listen(inMemoryBufferSize)
+
+	m := NewExternalServiceManager()
+	defer m.Close()
+
+	m.AddServiceWithOptions("item-service",
+		WithServerOptions(
+			grpcmock.WithListener(l),
+			grpcmock.RegisterService(grpctest.RegisterItemServiceServer),
+		),
+		WithHealthService(),
+	)
+
+	client := healthpb.NewHealthClient(dialInMemory(t, l))
+
+	watch := func(ctx context.Context) func() (healthpb.HealthCheckResponse_ServingStatus, error) {
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "grpctest.ItemService"})
+		require.NoError(t, err)
+
+		return func() (healthpb.HealthCheckResponse_ServingStatus, error) {
+			resp, err := stream.Recv()
+
+			return resp.GetStatus(), err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The application under test watches on start, without the scenario header.
+	recv := watch(ctx)
+
+	actual, err := recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, actual)
+
+	sc := &godog.Scenario{Id: "1"}
+
+	m.startScenario(sc, nil)
+
+	scenarioRecv := watch(metadata.AppendToOutgoingContext(ctx, ScenarioHeader, sc.Id))
+
+	actual, err = scenarioRecv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, actual)
+
+	require.NoError(t, m.setServiceHealthStatus(scenarioToContext(context.Background(), sc), "item-service", "grpctest.ItemService", "NOT_SERVING"))
+
+	actual, err = recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, actual)
+
+	actual, err = scenarioRecv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, actual)
+
+	m.endScenario(sc.Id)
+
+	// The watch of the scenario ends with it, and the status is reset once no scenario runs.
+	for err == nil {
+		_, err = scenarioRecv()
+	}
+
+	assert.Equal(t, codes.Canceled, status.Code(err))
+
+	actual, err = recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, actual)
+}
+
+func TestExternalServiceManager_SetServiceHealthStatus_Error(t *testing.T) {
+	t.Parallel()
+
+	m := NewExternalServiceManager()
+	m.servers["item-service"] = &wrappedServer{serviceScope: &serviceScope{}}
+	m.servers["health-service"] = &wrappedServer{serviceScope: &serviceScope{health: newHealthService(func(string) bool { return false })}}
+
+	assert.EqualError(t, m.setHealthStatus(context.Background(), "not-found", "SERVING"),
+		`grpc service not found, did you forget to setup the grpc service "not-found"?`)
+	assert.EqualError(t, m.setHealthStatus(context.Background(), "item-service", "SERVING"),
+		`health service is not enabled, did you forget to setup the grpc service "item-service" with grpcsteps.WithHealthService()?`)
+	assert.EqualError(t, m.setHealthStatus(context.Background(), "health-service", "unhealthy"),
+		`invalid health status: "unhealthy"`)
+	assert.NoError(t, m.setHealthStatus(context.Background(), "health-service", "NotServing"))
+}
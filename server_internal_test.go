@@ -1,10 +1,22 @@
 package grpcsteps
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"net"
+	"strings"
 	"testing"
 
+	"github.com/cucumber/godog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
 )
 
 func TestExternalServiceManager_ReceiveOneRequestWithPayloadFromFile_ReadFileError(t *testing.T) {
@@ -59,3 +71,163 @@ func TestExternalServiceManager_RespondWithError_InvalidCode(t *testing.T) {
 
 	assert.EqualError(t, err, expected)
 }
+
+func TestExternalServiceManager_SetServiceState_ServiceNotFound(t *testing.T) {
+	t.Parallel()
+
+	err := NewExternalServiceManager().setServiceState(context.Background(), "item-service", "created")
+	expected := `grpc service not found, did you forget to setup the grpc service "item-service"?`
+
+	assert.EqualError(t, err, expected)
+}
+
+func TestExternalServiceManager_AssertServiceState_Mismatched(t *testing.T) {
+	t.Parallel()
+
+	m := NewExternalServiceManager()
+	m.servers["item-service"] = &wrappedServer{serviceScope: &serviceScope{state: newServiceState()}}
+
+	err := m.assertServiceState(context.Background(), "item-service", "created")
+	expected := `unexpected state of grpc service "item-service", got "Started", want "created"`
+
+	assert.EqualError(t, err, expected)
+}
+
+func TestExternalServiceManager_Dial(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		opts     []ExternalServiceManagerOption
+	}{
+		{
+			scenario: "tcp",
+		},
+		{
+			scenario: "in memory",
+			opts:     []ExternalServiceManagerOption{WithInMemoryTransport()},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			m := NewExternalServiceManager(tc.opts...)
+			m.AddService("item-service", grpcmock.RegisterService(grpctest.RegisterItemServiceServer))
+
+			t.Cleanup(m.Close)
+
+			conn, err := m.dial(context.Background(), "item-service")
+			require.NoError(t, err)
+
+			assert.NoError(t, conn.Close())
+
+			_, err = m.dial(context.Background(), "unknown")
+
+			assert.ErrorIs(t, err, ErrGRPCServiceNotFound)
+		})
+	}
+}
+
+func TestExternalServiceManager_PauseOnFailure(t *testing.T) {
+	t.Parallel()
+
+	var message string
+
+	m := NewExternalServiceManager(WithInMemoryTransport())
+	m.pause = func(s string) {
+		message = s
+	}
+
+	m.AddService("item-service", grpcmock.RegisterService(grpctest.RegisterItemServiceServer))
+	defer m.Close()
+
+	m.servers["payment-service"] = &wrappedServer{Server: grpcmock.NewServer()}
+	defer m.servers["payment-service"].Close() // nolint: errcheck
+
+	m.pauseOnFailure("Get an item", errors.New("item not found")) // nolint: goerr113
+
+	expected := `scenario "Get an item" failed: item not found
+
+the grpc services are still up:
+- item-service: in memory
+- payment-service: ` + m.servers["payment-service"].Address() + `
+
+press Enter to continue`
+
+	assert.Equal(t, expected, message)
+}
+
+func TestExternalServiceManager_PauseOnUnmetExpectations(t *testing.T) {
+	t.Parallel()
+
+	var message string
+
+	m := NewExternalServiceManager(WithInMemoryTransport())
+	m.pause = func(s string) {
+		message = s
+	}
+
+	m.AddService("item-service", grpcmock.RegisterService(grpctest.RegisterItemServiceServer))
+	defer m.Close()
+
+	sc := &godog.Scenario{Id: "1", Name: "Get an item"}
+
+	ctx, tr := withTranscript(context.Background(), sc)
+	out := bytes.NewBuffer(nil)
+
+	tr.SetOutput(out)
+	tr.Record(newTranscriptEntry(transcriptSourceClient, "/grpctest.ItemService/GetItem", nil, nil, 0))
+
+	m.startScenario(sc, tr)
+
+	_, err := m.receiveRequest(scenarioToContext(context.Background(), sc), "item-service", "/grpctest.ItemService/GetItem", 1, nil)
+	require.NoError(t, err)
+
+	ctx, err = m.afterScenario(ctx, sc, nil)
+
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(message, `scenario "Get an item" failed: there are remaining expectations that were not met`))
+	assert.Equal(t, formatTranscript(sc.Name, tr.Entries()), out.String())
+	assert.Len(t, godog.Attachments(ctx), 1)
+
+	// The transcript is reported once per scenario.
+	tr.reportFailure(ctx)
+
+	assert.Equal(t, formatTranscript(sc.Name, tr.Entries()), out.String())
+}
+
+func TestPauseUntilResumed(t *testing.T) {
+	t.Parallel()
+
+	out := bytes.NewBuffer(nil)
+	pause := pauseUntilResumed(strings.NewReader("\n\n"), out)
+
+	// Every line resumes a pause, then there is no pause once the input is closed.
+	pause("first")
+	pause("second")
+	pause("third")
+
+	assert.Equal(t, "first\nsecond\nthird\n", out.String())
+}
+
+func dialInMemory(t *testing.T, l *bufconn.Listener) *grpc.ClientConn {
+	t.Helper()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close() // nolint: errcheck
+	})
+
+	return conn
+}
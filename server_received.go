@@ -0,0 +1,180 @@
+package grpcsteps
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.nhat.io/grpcmock"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// receivedRequest is a request that a mocked service receives.
+type receivedRequest struct {
+	method string
+	header metadata.MD
+
+	// receivedAt is when the request is received, and deadline is the deadline of its context, zero if it has none.
+	receivedAt time.Time
+	deadline   time.Time
+}
+
+// remainingDeadline returns the time left before the deadline when the request is received.
+func (r receivedRequest) remainingDeadline() time.Duration {
+	return r.deadline.Sub(r.receivedAt)
+}
+
+// receivedRequests keeps the requests that a mocked service receives in a scenario.
+type receivedRequests struct {
+	requests []receivedRequest
+
+	mu sync.Mutex
+}
+
+// Add records the request in context, there is nothing to record if the request is not sent by a scenario.
+func (r *receivedRequests) Add(ctx context.Context, method string) {
+	if r == nil {
+		return
+	}
+
+	receivedAt := time.Now()
+	header, _ := metadata.FromIncomingContext(ctx)
+	deadline, _ := ctx.Deadline()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests = append(r.requests, receivedRequest{
+		method:     method,
+		header:     header.Copy(),
+		receivedAt: receivedAt,
+		deadline:   deadline,
+	})
+}
+
+func (r *receivedRequests) All() []receivedRequest {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+// receivedInTrace returns the requests that are received in the trace.
+func receivedInTrace(received []receivedRequest, id trace.TraceID) []receivedRequest {
+	result := make([]receivedRequest, 0, len(received))
+
+	for _, r := range received {
+		if headerSpanContext(r.header).TraceID() == id {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// receivedServerOptions records the requests that a mocked service receives in the scope of the scenario that sends them.
+func receivedServerOptions(scope func(ctx context.Context) *serviceScope) []grpcmock.ServerOption {
+	return []grpcmock.ServerOption{
+		grpcmock.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			scope(ctx).received.Add(ctx, info.FullMethod)
+
+			return handler(ctx, req)
+		}),
+		grpcmock.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			scope(ss.Context()).received.Add(ss.Context(), info.FullMethod)
+
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// assertReceivedInTrace checks that the mocked service received a request in the trace.
+func assertReceivedInTrace(serviceID string, received []receivedRequest, id trace.TraceID) error {
+	if len(received) == 0 {
+		return fmt.Errorf("%w: %q received no grpc request", ErrTraceNotPropagated, serviceID)
+	}
+
+	var sb strings.Builder
+
+	for _, r := range received {
+		sc := headerSpanContext(r.header)
+
+		if sc.TraceID() == id {
+			return nil
+		}
+
+		if sc.IsValid() {
+			_, _ = fmt.Fprintf(&sb, "\n- %s in trace %s", r.method, sc.TraceID())
+		} else {
+			_, _ = fmt.Fprintf(&sb, "\n- %s without trace", r.method)
+		}
+	}
+
+	return fmt.Errorf("%w: %q received no grpc request in trace %s, received:%s", ErrTraceNotPropagated, serviceID, id, sb.String())
+}
+
+// assertReceivedWithHeader checks that the mocked service received every request with the values of the header.
+func assertReceivedWithHeader(serviceID string, received []receivedRequest, header string, values []string) error {
+	header = strings.ToLower(header)
+
+	if len(values) == 0 {
+		return fmt.Errorf("%w: the grpc request has no header %q", ErrHeaderNotPropagated, header)
+	}
+
+	if len(received) == 0 {
+		return fmt.Errorf("%w: %q received no grpc request", ErrHeaderNotPropagated, serviceID)
+	}
+
+	var sb strings.Builder
+
+	for _, r := range received {
+		if actual := r.header.Get(header); !reflect.DeepEqual(values, actual) {
+			_, _ = fmt.Fprintf(&sb, "\n- %s with %q", r.method, actual)
+		}
+	}
+
+	if sb.Len() == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q received the grpc requests without the header %q with values %q:%s",
+		ErrHeaderNotPropagated, serviceID, header, values, sb.String())
+}
+
+// assertReceivedWithDeadline checks that the mocked service received every request with a deadline, and with less than
+// maxRemaining left before it when it is positive.
+func assertReceivedWithDeadline(serviceID string, received []receivedRequest, maxRemaining time.Duration) error {
+	if len(received) == 0 {
+		return fmt.Errorf("%w: %q received no grpc request", ErrDeadlineNotPropagated, serviceID)
+	}
+
+	var sb strings.Builder
+
+	for _, r := range received {
+		if r.deadline.IsZero() {
+			_, _ = fmt.Fprintf(&sb, "\n- %s without deadline", r.method)
+		} else if remaining := r.remainingDeadline(); maxRemaining > 0 && remaining >= maxRemaining {
+			_, _ = fmt.Fprintf(&sb, "\n- %s with remaining deadline %s", r.method, remaining.Round(time.Millisecond))
+		}
+	}
+
+	if sb.Len() == 0 {
+		return nil
+	}
+
+	if maxRemaining > 0 {
+		return fmt.Errorf("%w: %q received the grpc requests without a remaining deadline less than %s:%s",
+			ErrDeadlineNotPropagated, serviceID, maxRemaining, sb.String())
+	}
+
+	return fmt.Errorf("%w: %q received the grpc requests without a deadline:%s", ErrDeadlineNotPropagated, serviceID, sb.String())
+}
@@ -0,0 +1,332 @@
+package grpcsteps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/grpcmock"
+	"go.opentelemetry.io/otel/trace"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+func TestReceivedRequests(t *testing.T) {
+	t.Parallel()
+
+	// Nothing is recorded without a scenario.
+	var none *receivedRequests
+
+	none.Add(context.Background(), "/grpctest.ItemService/GetItem")
+
+	assert.Empty(t, none.All())
+
+	r := &receivedRequests{}
+
+	deadline := time.Now().Add(time.Minute)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("locale", "en-US"))
+
+	r.Add(ctx, "/grpctest.ItemService/GetItem")
+	r.Add(context.Background(), "/grpctest.ItemService/ListItems")
+
+	actual := r.All()
+
+	for i := range actual {
+		assert.False(t, actual[i].receivedAt.IsZero())
+
+		actual[i].receivedAt = time.Time{}
+	}
+
+	expected := []receivedRequest{
+		{method: "/grpctest.ItemService/GetItem", header: metadata.Pairs("locale", "en-US"), deadline: deadline},
+		{method: "/grpctest.ItemService/ListItems", header: metadata.MD{}},
+	}
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestReceivedServerOptions_BuiltInServices(t *testing.T) {
+	t.Parallel()
+
+	l := bufconn.Listen(inMemoryBufferSize)
+
+	srv := newServer("item-service", 42,
+		WithServerOptions(
+			grpcmock.WithListener(l),
+			grpcmock.RegisterService(grpctest.RegisterItemServiceServer),
+		),
+		WithHealthService(),
+		withReflection(),
+	)
+
+	t.Cleanup(func() {
+		_ = srv.Close() // nolint: errcheck
+	})
+
+	conn := dialInMemory(t, l)
+	sc := &godog.Scenario{Id: "1"}
+
+	srv.startScenario(sc, nil)
+	expected, err := srv.scope(sc.Id).expect("/grpctest.ItemService/GetItem", 1, nil)
+	require.NoError(t, err)
+
+	expected.Return(`{"id": 42}`)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), ScenarioHeader, sc.Id)
+
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	stream, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Send(&reflectionv1.ServerReflectionRequest{
+		MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+	}))
+
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	require.NoError(t, stream.CloseSend())
+
+	_, err = grpctest.NewItemServiceClient(conn).GetItem(ctx, &grpctest.GetItemRequest{Id: 42})
+	require.NoError(t, err)
+
+	// Only the request to the mocked service is received.
+	actual := srv.scope(sc.Id).received.All()
+
+	require.Len(t, actual, 1)
+	assert.Equal(t, "/grpctest.ItemService/GetItem", actual[0].method)
+}
+
+func TestAssertReceivedInTrace(t *testing.T) {
+	t.Parallel()
+
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+
+	inTrace := metadata.Pairs("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	otherTrace := metadata.Pairs("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	testCases := []struct {
+		scenario      string
+		received      []receivedRequest
+		expectedError string
+	}{
+		{
+			scenario:      "no request",
+			expectedError: `trace not propagated: "item-service" received no grpc request`,
+		},
+		{
+			scenario: "in trace",
+			received: []receivedRequest{
+				{method: "/grpctest.ItemService/ListItems", header: otherTrace},
+				{method: "/grpctest.ItemService/GetItem", header: inTrace},
+			},
+		},
+		{
+			scenario: "not in trace",
+			received: []receivedRequest{
+				{method: "/grpctest.ItemService/ListItems", header: otherTrace},
+				{method: "/grpctest.ItemService/GetItem", header: metadata.MD{}},
+			},
+			expectedError: `trace not propagated: "item-service" received no grpc request in trace 4bf92f3577b34da6a3ce929d0e0e4736, received:
+- /grpctest.ItemService/ListItems in trace 0af7651916cd43dd8448eb211c80319c
+- /grpctest.ItemService/GetItem without trace`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := assertReceivedInTrace("item-service", tc.received, traceID)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestReceivedInTrace(t *testing.T) {
+	t.Parallel()
+
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+
+	inTrace := metadata.Pairs("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	otherTrace := metadata.Pairs("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	received := []receivedRequest{
+		{method: "/grpctest.ItemService/ListItems", header: otherTrace},
+		{method: "/grpctest.ItemService/GetItem", header: inTrace},
+		{method: "/grpctest.ItemService/CreateItems", header: metadata.MD{}},
+		{method: "/grpctest.ItemService/ListItems", header: inTrace},
+	}
+
+	expected := []receivedRequest{
+		{method: "/grpctest.ItemService/GetItem", header: inTrace},
+		{method: "/grpctest.ItemService/ListItems", header: inTrace},
+	}
+
+	assert.Equal(t, expected, receivedInTrace(received, traceID))
+	assert.Empty(t, receivedInTrace(nil, traceID))
+}
+
+func TestAssertReceivedWithHeader(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario      string
+		received      []receivedRequest
+		values        []string
+		expectedError string
+	}{
+		{
+			scenario:      "no header",
+			received:      []receivedRequest{{method: "/grpctest.ItemService/GetItem", header: metadata.MD{}}},
+			expectedError: `header not propagated: the grpc request has no header "x-tenant-id"`,
+		},
+		{
+			scenario:      "no request",
+			values:        []string{"tenant-42"},
+			expectedError: `header not propagated: "item-service" received no grpc request`,
+		},
+		{
+			scenario: "propagated",
+			received: []receivedRequest{
+				{method: "/grpctest.ItemService/GetItem", header: metadata.Pairs("x-tenant-id", "tenant-42")},
+				{method: "/grpctest.ItemService/ListItems", header: metadata.Pairs("X-Tenant-Id", "tenant-42")},
+			},
+			values: []string{"tenant-42"},
+		},
+		{
+			scenario: "several values",
+			received: []receivedRequest{
+				{method: "/grpctest.ItemService/GetItem", header: metadata.Pairs("x-tenant-id", "tenant-42", "x-tenant-id", "tenant-43")},
+			},
+			values: []string{"tenant-42", "tenant-43"},
+		},
+		{
+			scenario: "not propagated",
+			received: []receivedRequest{
+				{method: "/grpctest.ItemService/GetItem", header: metadata.Pairs("x-tenant-id", "tenant-42")},
+				{method: "/grpctest.ItemService/GetItem", header: metadata.Pairs("x-tenant-id", "tenant-43")},
+				{method: "/grpctest.ItemService/ListItems", header: metadata.MD{}},
+			},
+			values: []string{"tenant-42"},
+			expectedError: `header not propagated: "item-service" received the grpc requests without the header "x-tenant-id" with values ["tenant-42"]:
+- /grpctest.ItemService/GetItem with ["tenant-43"]
+- /grpctest.ItemService/ListItems with []`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := assertReceivedWithHeader("item-service", tc.received, "X-Tenant-Id", tc.values)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestAssertReceivedWithDeadline(t *testing.T) {
+	t.Parallel()
+
+	receivedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		scenario      string
+		received      []receivedRequest
+		maxRemaining  time.Duration
+		expectedError string
+	}{
+		{
+			scenario:      "no request",
+			expectedError: `deadline not propagated: "item-service" received no grpc request`,
+		},
+		{
+			scenario: "deadline",
+			received: []receivedRequest{
+				{method: "/grpctest.ItemService/GetItem", receivedAt: receivedAt, deadline: receivedAt.Add(time.Hour)},
+			},
+		},
+		{
+			scenario: "no deadline",
+			received: []receivedRequest{
+				{method: "/grpctest.ItemService/GetItem", receivedAt: receivedAt, deadline: receivedAt.Add(time.Second)},
+				{method: "/grpctest.ItemService/ListItems", receivedAt: receivedAt},
+			},
+			expectedError: `deadline not propagated: "item-service" received the grpc requests without a deadline:
+- /grpctest.ItemService/ListItems without deadline`,
+		},
+		{
+			scenario: "remaining deadline is less than max",
+			received: []receivedRequest{
+				{method: "/grpctest.ItemService/GetItem", receivedAt: receivedAt, deadline: receivedAt.Add(300 * time.Millisecond)},
+			},
+			maxRemaining: 500 * time.Millisecond,
+		},
+		{
+			scenario: "remaining deadline is not less than max",
+			received: []receivedRequest{
+				{method: "/grpctest.ItemService/GetItem", receivedAt: receivedAt, deadline: receivedAt.Add(300 * time.Millisecond)},
+				{method: "/grpctest.ItemService/GetItem", receivedAt: receivedAt, deadline: receivedAt.Add(500 * time.Millisecond)},
+				{method: "/grpctest.ItemService/ListItems", receivedAt: receivedAt},
+			},
+			maxRemaining: 500 * time.Millisecond,
+			expectedError: `deadline not propagated: "item-service" received the grpc requests without a remaining deadline less than 500ms:
+- /grpctest.ItemService/GetItem with remaining deadline 500ms
+- /grpctest.ItemService/ListItems without deadline`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := assertReceivedWithDeadline("item-service", tc.received, tc.maxRemaining)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestExternalServiceManager_AssertReceivedWithRemainingDeadline_Error(t *testing.T) {
+	t.Parallel()
+
+	m := NewExternalServiceManager()
+	m.servers["item-service"] = &wrappedServer{serviceScope: &serviceScope{}}
+
+	assert.EqualError(t, m.assertReceivedWithRemainingDeadline(context.Background(), "item-service", "0s"),
+		`invalid deadline: "0s", a positive duration expected`)
+	assert.EqualError(t, m.assertReceivedWithRemainingDeadline(context.Background(), "item-service", "-1s"),
+		`invalid deadline: "-1s", a positive duration expected`)
+	assert.EqualError(t, m.assertReceivedWithRemainingDeadline(context.Background(), "item-service", "1s"),
+		`deadline not propagated: "item-service" received no grpc request`)
+}
@@ -0,0 +1,386 @@
+package grpcsteps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cucumber/godog"
+	xmatcher "go.nhat.io/grpcmock/matcher"
+	"go.nhat.io/grpcmock/planner"
+	"go.nhat.io/grpcmock/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const recordedInteractionsFile = "interactions.json"
+
+// WithRecordFrom forwards the requests that do not match any expectation to the upstream service, like WithUpstream, and
+// records every interaction in the directory. For a service "item-service", the fixtures and the interactions are written
+// to DIR/item-service/ and a feature that mocks them is generated at DIR/item-service.feature. Bidirectional streams are
+// forwarded but not recorded.
+//
+//	m.AddServiceWithOptions("item-service",
+//		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+//		grpcsteps.WithRecordFrom("localhost:9000", "testdata/recorded"),
+//	)
+func WithRecordFrom(addr, dir string, opts ...grpc.DialOption) ExternalServiceOption {
+	return func(c *serviceConfig) {
+		WithUpstream(addr, opts...)(c)
+
+		c.recordDir = dir
+	}
+}
+
+// WithReplayFrom serves the interactions that were recorded with WithRecordFrom in the directory, for the requests that do
+// not match any expectation. When several interactions match a request, they are served in the recorded order and the
+// last one is repeated.
+//
+//	m.AddServiceWithOptions("item-service",
+//		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+//		grpcsteps.WithReplayFrom("testdata/recorded"),
+//	)
+func WithReplayFrom(dir string) ExternalServiceOption {
+	return func(c *serviceConfig) {
+		c.replayDir = dir
+	}
+}
+
+// recordedInteraction is a request and its response. The payloads are stored in files, next to the interactions.
+type recordedInteraction struct {
+	Scenario string `json:"scenario,omitempty"`
+	Method   string `json:"method"`
+	Request  string `json:"request"`
+	Code     string `json:"code"`
+	Response string `json:"response,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// recorder writes the interactions of a service as fixtures, and generates a feature that mocks them.
+type recorder struct {
+	serviceID string
+	dir       string
+
+	interactions []recordedInteraction
+	// errs keeps the first recording error of each scenario.
+	errs map[string]error
+
+	mu sync.Mutex
+}
+
+// Record writes the fixtures of an interaction of the scenario, nil for the default scope, and updates the interactions
+// and the generated feature. The recording error, if any, is kept for the scenario, see Err().
+func (r *recorder) Record(sc *godog.Scenario, svc service.Method, requests, responses []interface{}, rpcErr error) {
+	var id, name string
+
+	if sc != nil {
+		id, name = sc.Id, sc.Name
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.record(name, svc, requests, responses, rpcErr); err != nil {
+		if _, ok := r.errs[id]; !ok {
+			r.errs[id] = fmt.Errorf("could not record the interaction of %s: %w", svc.FullName(), err)
+		}
+	}
+}
+
+// Err returns the first recording error of the scenario, if any.
+func (r *recorder) Err(scenarioID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.errs[scenarioID]
+}
+
+func (r *recorder) endScenario(scenarioID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.errs, scenarioID)
+}
+
+func (r *recorder) record(scenario string, svc service.Method, requests, responses []interface{}, rpcErr error) error {
+	if service.IsMethodBidirectionalStream(svc.MethodType) {
+		return nil
+	}
+
+	st := status.Convert(rpcErr)
+	prefix := fmt.Sprintf("%04d-%s", len(r.interactions)+1, svc.MethodName)
+
+	i := recordedInteraction{
+		Scenario: scenario,
+		Method:   svc.FullName(),
+		Request:  prefix + ".request.json",
+		Code:     st.Code().String(),
+	}
+
+	payload, err := marshalRecordedPayload(requests, service.IsMethodClientStream(svc.MethodType))
+	if err != nil {
+		return err
+	}
+
+	if err := r.writeFile(i.Request, payload); err != nil {
+		return err
+	}
+
+	if st.Code() == codes.OK {
+		i.Response = prefix + ".response.json"
+
+		payload, err := marshalRecordedPayload(responses, service.IsMethodServerStream(svc.MethodType))
+		if err != nil {
+			return err
+		}
+
+		if err := r.writeFile(i.Response, payload); err != nil {
+			return err
+		}
+	} else {
+		i.Message = st.Message()
+	}
+
+	r.interactions = append(r.interactions, i)
+
+	payload, err = json.MarshalIndent(r.interactions, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := r.writeFile(recordedInteractionsFile, payload); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(r.dir, r.serviceID+".feature"), []byte(r.feature()), 0o644) // nolint: gosec
+}
+
+func (r *recorder) writeFile(name string, data []byte) error {
+	return os.WriteFile(filepath.Join(r.dir, r.serviceID, name), append(data, '\n'), 0o644) // nolint: gosec
+}
+
+// feature generates a feature that mocks the recorded interactions, one scenario per recorded scenario.
+func (r *recorder) feature() string {
+	var sb strings.Builder
+
+	_, _ = fmt.Fprintf(&sb, "Feature: Recorded interactions of %q\n", r.serviceID)
+
+	for n, i := range r.interactions {
+		if n == 0 || i.Scenario != r.interactions[n-1].Scenario {
+			name := i.Scenario
+			if name == "" {
+				name = "Recorded interactions"
+			}
+
+			_, _ = fmt.Fprintf(&sb, "\n    Scenario: %s\n", name)
+		} else {
+			sb.WriteString("\n")
+		}
+
+		_, _ = fmt.Fprintf(&sb, "        Given %q receives a grpc request %q with payload from file %q\n",
+			r.serviceID, i.Method, filepath.Join(r.dir, r.serviceID, i.Request),
+		)
+
+		if i.Response != "" {
+			_, _ = fmt.Fprintf(&sb, "        And the grpc service responds with payload from file %q\n",
+				filepath.Join(r.dir, r.serviceID, i.Response),
+			)
+
+			continue
+		}
+
+		_, _ = fmt.Fprintf(&sb, "        And the grpc service responds with code %q and error:\n", i.Code)
+		_, _ = fmt.Fprintf(&sb, "        \"\"\"\n        %s\n        \"\"\"\n", strings.ReplaceAll(i.Message, "\n", "\n        "))
+	}
+
+	return sb.String()
+}
+
+func newRecorder(serviceID, dir string) (*recorder, error) {
+	if err := os.MkdirAll(filepath.Join(dir, serviceID), 0o755); err != nil { // nolint: gosec
+		return nil, err
+	}
+
+	return &recorder{
+		serviceID: serviceID,
+		dir:       dir,
+		errs:      make(map[string]error),
+	}, nil
+}
+
+// marshalRecordedPayload marshals the requests and the responses like the client payloads, and like grpcmock when it
+// matches the payload, so the fixtures could be used as expectations. The messages of a stream are in an array.
+func marshalRecordedPayload(messages []interface{}, streamed bool) ([]byte, error) {
+	if !streamed {
+		return json.MarshalIndent(messages[0], "", "    ")
+	}
+
+	if messages == nil {
+		messages = []interface{}{}
+	}
+
+	return json.MarshalIndent(messages, "", "    ")
+}
+
+var _ fallback = (*replayer)(nil)
+
+type replayedInteraction struct {
+	method   string
+	request  string
+	response sequencedResponse
+	// calls counts the calls of every scenario.
+	calls map[string]int
+}
+
+// replayer serves the recorded interactions of a service, from the beginning for every scenario.
+type replayer struct {
+	interactions []*replayedInteraction
+
+	mu sync.Mutex
+}
+
+func (r *replayer) expectation(sc *godog.Scenario, svc service.Method, in interface{}) (planner.Expectation, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		found    *replayedInteraction
+		scenario string
+	)
+
+	if sc != nil {
+		scenario = sc.Id
+	}
+
+	for _, i := range r.interactions {
+		if i.method != svc.FullName() || !matchRecordedRequest(svc.MethodType, i.request, in) {
+			continue
+		}
+
+		found = i
+
+		if i.calls[scenario] == 0 {
+			break
+		}
+	}
+
+	if found == nil {
+		return nil, false
+	}
+
+	found.calls[scenario]++
+
+	return &replayExpectation{
+		fallbackExpectation: fallbackExpectation{svc: svc},
+		response:            found.response,
+	}, true
+}
+
+func (r *replayer) endScenario(scenarioID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, i := range r.interactions {
+		delete(i.calls, scenarioID)
+	}
+}
+
+func newReplayer(serviceID, dir string) (*replayer, error) {
+	dir = filepath.Join(dir, serviceID)
+
+	data, err := os.ReadFile(filepath.Join(dir, recordedInteractionsFile)) // nolint: gosec
+	if err != nil {
+		return nil, err
+	}
+
+	var recorded []recordedInteraction
+
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, err
+	}
+
+	r := &replayer{interactions: make([]*replayedInteraction, 0, len(recorded))}
+
+	for _, i := range recorded {
+		request, err := os.ReadFile(filepath.Join(dir, i.Request)) // nolint: gosec
+		if err != nil {
+			return nil, err
+		}
+
+		code, err := toStatusCode(i.Code)
+		if err != nil {
+			return nil, err
+		}
+
+		response := sequencedResponse{code: code, message: i.Message}
+
+		if i.Response != "" {
+			payload, err := os.ReadFile(filepath.Join(dir, i.Response)) // nolint: gosec
+			if err != nil {
+				return nil, err
+			}
+
+			response.payload = string(payload)
+		}
+
+		r.interactions = append(r.interactions, &replayedInteraction{
+			method:   i.Method,
+			request:  string(request),
+			response: response,
+			calls:    make(map[string]int),
+		})
+	}
+
+	return r, nil
+}
+
+func matchRecordedRequest(methodType service.Type, request string, in interface{}) bool {
+	m := xmatcher.UnaryPayload(request)
+
+	if service.IsMethodClientStream(methodType) {
+		m = xmatcher.ClientStreamPayload(request)
+	}
+
+	matched, err := m.Match(in)
+
+	return err == nil && matched
+}
+
+var _ planner.Expectation = (*replayExpectation)(nil)
+
+// replayExpectation serves a recorded response.
+type replayExpectation struct {
+	fallbackExpectation
+
+	response sequencedResponse
+}
+
+func (e *replayExpectation) Handle(ctx context.Context, in interface{}, out interface{}) error {
+	responses := newResponseSequence([]sequencedResponse{e.response})
+
+	if service.IsMethodServerStream(e.svc.MethodType) {
+		return responses.handleServerStream(ctx, in, out.(grpc.ServerStream))
+	}
+
+	resp, err := responses.handleUnary(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	if err := protojson.Unmarshal([]byte(resp.(string)), out.(proto.Message)); err != nil { // nolint: errcheck
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if service.IsMethodClientStream(e.svc.MethodType) {
+		return in.(grpc.ServerStream).SendMsg(out)
+	}
+
+	return nil
+}
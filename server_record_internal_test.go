@@ -0,0 +1,189 @@
+package grpcsteps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cucumber/godog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/grpcmock/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+func getItemMethod() service.Method {
+	return service.Method{
+		ServiceName: "grpctest.ItemService",
+		MethodName:  "GetItem",
+		MethodType:  service.TypeUnary,
+		Input:       &grpctest.GetItemRequest{},
+		Output:      &grpctest.Item{},
+	}
+}
+
+func TestReplayer_Expectation(t *testing.T) {
+	t.Parallel()
+
+	svc := getItemMethod()
+	first := sequencedResponse{code: codes.OK, payload: `{"id": 42, "name": "Item #42"}`}
+	second := sequencedResponse{code: codes.NotFound, message: "Item #42 not found"}
+
+	r := &replayer{interactions: []*replayedInteraction{
+		{method: svc.FullName(), request: `{"id": 42}`, response: first, calls: make(map[string]int)},
+		{method: svc.FullName(), request: `{"id": 42}`, response: second, calls: make(map[string]int)},
+	}}
+
+	firstScenario := &godog.Scenario{Id: "1"}
+	secondScenario := &godog.Scenario{Id: "2"}
+
+	replayedIn := func(sc *godog.Scenario, in interface{}) *sequencedResponse {
+		expected, ok := r.expectation(sc, svc, in)
+		if !ok {
+			return nil
+		}
+
+		return &expected.(*replayExpectation).response // nolint: errcheck
+	}
+
+	replayed := func(in interface{}) *sequencedResponse {
+		return replayedIn(firstScenario, in)
+	}
+
+	assert.Nil(t, replayed(&grpctest.GetItemRequest{Id: 43}))
+
+	// The interactions are served in order, then the last one is repeated.
+	assert.Equal(t, &first, replayed(&grpctest.GetItemRequest{Id: 42}))
+	assert.Equal(t, &second, replayed(&grpctest.GetItemRequest{Id: 42}))
+	assert.Equal(t, &second, replayed(&grpctest.GetItemRequest{Id: 42}))
+
+	// Every scenario is served from the beginning.
+	assert.Equal(t, &first, replayedIn(secondScenario, &grpctest.GetItemRequest{Id: 42}))
+	assert.Equal(t, &first, replayedIn(nil, &grpctest.GetItemRequest{Id: 42}))
+
+	r.endScenario(firstScenario.Id)
+
+	assert.Equal(t, &first, replayed(&grpctest.GetItemRequest{Id: 42}))
+	assert.Equal(t, &second, replayedIn(secondScenario, &grpctest.GetItemRequest{Id: 42}))
+}
+
+func TestRecorder_Record(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	r, err := newRecorder("item-service", dir)
+	require.NoError(t, err)
+
+	sc := &godog.Scenario{Id: "1", Name: "Get an item"}
+
+	r.Record(sc, getItemMethod(), []interface{}{&grpctest.GetItemRequest{Id: 42}}, nil,
+		status.Error(codes.NotFound, "Item #42\nnot found"),
+	)
+	require.NoError(t, r.Err(sc.Id))
+
+	expected := `Feature: Recorded interactions of "item-service"
+
+    Scenario: Get an item
+        Given "item-service" receives a grpc request "/grpctest.ItemService/GetItem" with payload from file "` + filepath.Join(dir, "item-service", "0001-GetItem.request.json") + `"
+        And the grpc service responds with code "NotFound" and error:
+        """
+        Item #42
+        not found
+        """
+`
+
+	actual, err := os.ReadFile(filepath.Join(dir, "item-service.feature"))
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, string(actual))
+
+	replayer, err := newReplayer("item-service", dir)
+	require.NoError(t, err)
+
+	require.Len(t, replayer.interactions, 1)
+	assert.Equal(t, sequencedResponse{code: codes.NotFound, message: "Item #42\nnot found"}, replayer.interactions[0].response)
+}
+
+func TestRecorder_RecordBidirectionalStream(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	r, err := newRecorder("item-service", dir)
+	require.NoError(t, err)
+
+	r.Record(nil, service.Method{ServiceName: "grpctest.ItemService", MethodName: "TransformItems", MethodType: service.TypeBidirectionalStream}, nil, nil, nil)
+	require.NoError(t, r.Err(""))
+
+	assert.NoFileExists(t, filepath.Join(dir, "item-service.feature"))
+}
+
+func TestRecorder_RecordConcurrentScenarios(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	r, err := newRecorder("item-service", dir)
+	require.NoError(t, err)
+
+	first := &godog.Scenario{Id: "1", Name: "First"}
+	second := &godog.Scenario{Id: "2", Name: "Second"}
+	resp := []interface{}{&grpctest.Item{Id: 42}}
+
+	r.Record(first, getItemMethod(), []interface{}{&grpctest.GetItemRequest{Id: 42}}, resp, nil)
+	r.Record(second, getItemMethod(), []interface{}{&grpctest.GetItemRequest{Id: 42}}, resp, nil)
+	r.Record(first, getItemMethod(), []interface{}{&grpctest.GetItemRequest{Id: 42}}, resp, nil)
+
+	scenarios := make([]string, 0, len(r.interactions))
+
+	for _, i := range r.interactions {
+		scenarios = append(scenarios, i.Scenario)
+	}
+
+	assert.Equal(t, []string{"First", "Second", "First"}, scenarios)
+}
+
+func TestRecorder_RecordError(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	r, err := newRecorder("item-service", dir)
+	require.NoError(t, err)
+
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "item-service")))
+
+	sc := &godog.Scenario{Id: "1", Name: "Get an item"}
+
+	r.Record(sc, getItemMethod(), []interface{}{&grpctest.GetItemRequest{Id: 42}}, []interface{}{&grpctest.Item{Id: 42}}, nil)
+
+	err = r.Err(sc.Id)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not record the interaction of /grpctest.ItemService/GetItem: open ")
+
+	// The other scenarios are not affected, and the error is removed when the scenario ends.
+	assert.NoError(t, r.Err("2"))
+
+	r.endScenario(sc.Id)
+
+	assert.NoError(t, r.Err(sc.Id))
+}
+
+func TestMarshalRecordedPayload(t *testing.T) {
+	t.Parallel()
+
+	actual, err := marshalRecordedPayload([]interface{}{&grpctest.CreateItemsResponse{NumItems: 2}}, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "{\n    \"num_items\": 2\n}", string(actual))
+
+	actual, err = marshalRecordedPayload(nil, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[]", string(actual))
+}
@@ -0,0 +1,163 @@
+package grpcsteps
+
+import (
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// withReflection serves the server reflection services (grpc.reflection.v1 and grpc.reflection.v1alpha) on the mocked
+// service.
+func withReflection() ExternalServiceOption {
+	return func(c *serviceConfig) {
+		c.reflection = true
+	}
+}
+
+// reflectionService describes the services of a mocked service, including the dynamic ones.
+type reflectionService struct {
+	v1      reflectionv1.ServerReflectionServer
+	v1alpha reflectionv1alpha.ServerReflectionServer
+}
+
+func (r *reflectionService) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	switch info.FullMethod {
+	case reflectionv1.ServerReflection_ServerReflectionInfo_FullMethodName:
+		return r.v1.ServerReflectionInfo(v1ReflectionStream{ServerStream: ss})
+
+	case reflectionv1alpha.ServerReflection_ServerReflectionInfo_FullMethodName:
+		return r.v1alpha.ServerReflectionInfo(v1alphaReflectionStream{ServerStream: ss})
+	}
+
+	return handler(srv, ss)
+}
+
+func newReflectionService(srv *grpcmock.Server, dynamic *dynamicServices) *reflectionService {
+	opts := reflection.ServerOptions{
+		Services:           reflectionServices{server: srv, dynamic: dynamic},
+		DescriptorResolver: protoregistry.GlobalFiles,
+	}
+
+	if dynamic != nil {
+		opts.DescriptorResolver = descriptorResolvers{dynamic.files, protoregistry.GlobalFiles}
+	}
+
+	return &reflectionService{
+		v1:      reflection.NewServerV1(opts),
+		v1alpha: reflection.NewServer(opts),
+	}
+}
+
+// reflectionServerOptions returns the options that let the reflection service answer the requests before they reach the
+// faults and the expectations. The reflection service is looked up when the request comes, there is none unless the
+// manager has WithReflection().
+func reflectionServerOptions(r func() *reflectionService) []grpcmock.ServerOption {
+	return []grpcmock.ServerOption{
+		grpcmock.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if r := r(); r != nil {
+				return r.streamInterceptor(srv, ss, info, handler)
+			}
+
+			return handler(srv, ss)
+		}),
+	}
+}
+
+var _ reflection.ServiceInfoProvider = (*reflectionServices)(nil)
+
+// reflectionServices lists the services that the mocked service serves. grpcmock does not expose them, so the known
+// services are checked one by one.
+type reflectionServices struct {
+	server  *grpcmock.Server
+	dynamic *dynamicServices
+}
+
+func (s reflectionServices) GetServiceInfo() map[string]grpc.ServiceInfo {
+	result := make(map[string]grpc.ServiceInfo)
+
+	protoregistry.GlobalFiles.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			name := string(fd.Services().Get(i).FullName())
+
+			if servesService(s.server, nil, name) {
+				result[name] = grpc.ServiceInfo{}
+			}
+		}
+
+		return true
+	})
+
+	if s.dynamic != nil {
+		for name := range s.dynamic.services {
+			result[name] = grpc.ServiceInfo{}
+		}
+	}
+
+	return result
+}
+
+var _ protodesc.Resolver = (descriptorResolvers)(nil)
+
+// descriptorResolvers looks up the descriptors in order.
+type descriptorResolvers []protodesc.Resolver
+
+func (r descriptorResolvers) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	for _, resolver := range r {
+		if fd, err := resolver.FindFileByPath(path); err == nil {
+			return fd, nil
+		}
+	}
+
+	return nil, protoregistry.NotFound
+}
+
+func (r descriptorResolvers) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	for _, resolver := range r {
+		if d, err := resolver.FindDescriptorByName(name); err == nil {
+			return d, nil
+		}
+	}
+
+	return nil, protoregistry.NotFound
+}
+
+type v1ReflectionStream struct {
+	grpc.ServerStream
+}
+
+func (s v1ReflectionStream) Send(m *reflectionv1.ServerReflectionResponse) error {
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s v1ReflectionStream) Recv() (*reflectionv1.ServerReflectionRequest, error) {
+	m := &reflectionv1.ServerReflectionRequest{}
+
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+type v1alphaReflectionStream struct {
+	grpc.ServerStream
+}
+
+func (s v1alphaReflectionStream) Send(m *reflectionv1alpha.ServerReflectionResponse) error {
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s v1alphaReflectionStream) Recv() (*reflectionv1alpha.ServerReflectionRequest, error) {
+	m := &reflectionv1alpha.ServerReflectionRequest{}
+
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,140 @@
+package grpcsteps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/grpcmock"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+// writeEchoDescriptorSet writes a descriptor set of a service that has no generated code.
+func writeEchoDescriptorSet(t *testing.T) string {
+	t.Helper()
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(emptypb.File_google_protobuf_empty_proto),
+			{
+				Name:       proto.String("echo.proto"),
+				Package:    proto.String("echotest"),
+				Dependency: []string{"google/protobuf/empty.proto"},
+				Syntax:     proto.String("proto3"),
+				Service: []*descriptorpb.ServiceDescriptorProto{{
+					Name: proto.String("EchoService"),
+					Method: []*descriptorpb.MethodDescriptorProto{{
+						Name:       proto.String("Echo"),
+						InputType:  proto.String(".google.protobuf.Empty"),
+						OutputType: proto.String(".google.protobuf.Empty"),
+					}},
+				}},
+			},
+		},
+	}
+
+	data, err := proto.Marshal(set)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "echo.protoset")
+
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+
+	return file
+}
+
+func TestReflectionService(t *testing.T) {
+	t.Parallel()
+
+	l := bufconn.Listen(inMemoryBufferSize)
+
+	srv := newServer("item-service", 42,
+		WithServerOptions(grpcmock.WithListener(l)),
+		withReflection(),
+		WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+		RegisterServiceFromDescriptorSet(writeEchoDescriptorSet(t)),
+	)
+
+	t.Cleanup(func() {
+		_ = srv.Close() // nolint: errcheck
+	})
+
+	conn := dialInMemory(t, l)
+
+	t.Run("v1", func(t *testing.T) {
+		t.Parallel()
+
+		stream, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&reflectionv1.ServerReflectionRequest{
+			MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+		}))
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+
+		services := make([]string, 0)
+
+		for _, s := range resp.GetListServicesResponse().GetService() {
+			services = append(services, s.GetName())
+		}
+
+		expected := []string{
+			"echotest.EchoService",
+			"grpc.reflection.v1.ServerReflection",
+			"grpc.reflection.v1alpha.ServerReflection",
+			"grpctest.ItemService",
+		}
+
+		assert.Equal(t, expected, services)
+
+		require.NoError(t, stream.Send(&reflectionv1.ServerReflectionRequest{
+			MessageRequest: &reflectionv1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "echotest.EchoService"},
+		}))
+
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+
+		files := resp.GetFileDescriptorResponse().GetFileDescriptorProto()
+		require.Len(t, files, 2)
+
+		var fd descriptorpb.FileDescriptorProto
+
+		require.NoError(t, proto.Unmarshal(files[0], &fd))
+		assert.Equal(t, "echo.proto", fd.GetName())
+	})
+
+	t.Run("v1alpha", func(t *testing.T) {
+		t.Parallel()
+
+		stream, err := reflectionv1alpha.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&reflectionv1alpha.ServerReflectionRequest{
+			MessageRequest: &reflectionv1alpha.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "grpctest.ItemService"},
+		}))
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+
+		files := resp.GetFileDescriptorResponse().GetFileDescriptorProto()
+		require.NotEmpty(t, files)
+
+		var fd descriptorpb.FileDescriptorProto
+
+		require.NoError(t, proto.Unmarshal(files[0], &fd))
+		assert.Equal(t, grpctest.File_resources_protobuf_service_proto.Path(), fd.GetName())
+	})
+}
@@ -0,0 +1,168 @@
+package grpcsteps
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cucumber/godog"
+	"go.nhat.io/grpcmock"
+	"go.nhat.io/grpcmock/planner"
+	"go.nhat.io/grpcmock/service"
+	"google.golang.org/grpc/metadata"
+)
+
+// ScenarioHeader is the metadata key of the scenario that sends the request. The client sends it to the services that have
+// WithScenarioHeader() or WithExternalService(), so the mocked services serve the request with the expectations of that
+// scenario, even if the scenarios run concurrently. An application under test that calls the mocked services has to
+// forward it, unless the scenarios run one at a time.
+const ScenarioHeader = "x-godog-scenario"
+
+type scenarioCtxKey struct{}
+
+func scenarioToContext(ctx context.Context, sc *godog.Scenario) context.Context {
+	return context.WithValue(ctx, scenarioCtxKey{}, sc)
+}
+
+// ScenarioFromContext returns the scenario that sends the request, or nil if the request is not sent by a scenario. The
+// client interceptors and stats handlers could use it to tell the scenarios apart.
+func ScenarioFromContext(ctx context.Context) *godog.Scenario {
+	sc, _ := ctx.Value(scenarioCtxKey{}).(*godog.Scenario) // nolint: errcheck
+
+	return sc
+}
+
+func scenarioFromContext(ctx context.Context) string {
+	if sc := ScenarioFromContext(ctx); sc != nil {
+		return sc.Id
+	}
+
+	return ""
+}
+
+func scenarioFromIncomingContext(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	ids := md.Get(ScenarioHeader)
+	if len(ids) == 0 {
+		return "", false
+	}
+
+	return ids[0], true
+}
+
+// serviceScope keeps the expectations, the state and the faults of a service in a scenario.
+type serviceScope struct {
+	// server keeps the expectations, it is not started unless it is the one that serves the requests.
+	server  *grpcmock.Server
+	served  *grpcmock.Server
+	planner *statefulPlanner
+	state   *serviceState
+	headers *headerMatcher
+	faults  *faultInjector
+	// health answers the health checks, if the service has WithHealthService().
+	health *healthService
+	// transcript and received record the requests of the scenario, there are none in the default scope.
+	transcript *transcript
+	received   *receivedRequests
+}
+
+func (s *serviceScope) expect(method string, times uint, payload *string) (expectation, error) {
+	svc := grpcmock.FindServerMethod(s.served, method)
+	if svc == nil {
+		return nil, fmt.Errorf("%w: %s", ErrGRPCMethodNotFound, method)
+	}
+
+	if s.server != s.served && grpcmock.FindServerMethod(s.server, method) == nil {
+		grpcmock.RegisterServiceFromMethods(*svc)(s.server)
+	}
+
+	var expected expectation
+
+	switch svc.MethodType {
+	case service.TypeUnary:
+		expected = &unaryExpectation{UnaryExpectation: s.server.ExpectUnary(method), state: s.state, headers: s.headers}
+
+	case service.TypeClientStream:
+		expected = &clientStreamExpectation{ClientStreamExpectation: s.server.ExpectClientStream(method), state: s.state, headers: s.headers}
+
+	case service.TypeServerStream:
+		_, dynamic := svc.Input.(*dynamicMessage)
+
+		expected = &serverStreamExpectation{
+			ServerStreamExpectation: s.server.ExpectServerStream(method),
+			state:                   s.state,
+			headers:                 s.headers,
+			dynamic:                 dynamic,
+		}
+
+	case service.TypeBidirectionalStream:
+		return nil, fmt.Errorf("%w: %s %s", ErrGRPCMethodNotSupported, svc.MethodType, method)
+	}
+
+	expected.Times(times)
+
+	if payload != nil {
+		expected.WithPayload(*payload)
+	}
+
+	return expected, nil
+}
+
+func newServiceScope(served *grpcmock.Server, randomSeed int64, sc *godog.Scenario, fallbacks ...fallback) *serviceScope {
+	state := newServiceState()
+	headers := newHeaderMatcher()
+	p := newStatefulPlanner(state, headers)
+	p.scenario = sc
+
+	for _, f := range fallbacks {
+		p.addFallback(f)
+	}
+
+	return &serviceScope{
+		server:  grpcmock.NewUnstartedServer(grpcmock.WithPlanner(p)),
+		served:  served,
+		planner: p,
+		state:   state,
+		headers: headers,
+		faults:  newFaultInjector(randomSeed),
+	}
+}
+
+var _ planner.Planner = (*scenarioPlanner)(nil)
+
+// scenarioPlanner plans the requests with the expectations of the scenario that sends them. The expectations that are
+// set directly on the server belong to the default scope.
+type scenarioPlanner struct {
+	server *wrappedServer
+}
+
+func (p scenarioPlanner) IsEmpty() bool {
+	// The scope of the request decides.
+	return false
+}
+
+func (p scenarioPlanner) Expect(expect planner.Expectation) {
+	p.server.planner.Expect(expect)
+}
+
+// Plan picks the expectation in the scope of the request, its handling is deferred until the lock of grpcmock is
+// released.
+func (p scenarioPlanner) Plan(ctx context.Context, req service.Method, in interface{}) (planner.Expectation, error) {
+	expected, err := p.server.requestScope(ctx).planner.Plan(ctx, req, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return deferredExpectation{Expectation: expected}, nil
+}
+
+func (p scenarioPlanner) Remain() []planner.Expectation {
+	return p.server.planner.Remain()
+}
+
+func (p scenarioPlanner) Reset() {
+	p.server.planner.Reset()
+}
@@ -0,0 +1,95 @@
+package grpcsteps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cucumber/godog"
+	"github.com/stretchr/testify/assert"
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+func TestWrappedServer_RequestScope(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer("item-service", 42, WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)))
+	defer srv.Close() // nolint: errcheck
+
+	withScenario := func(id string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(ScenarioHeader, id))
+	}
+
+	// No scenario is running.
+	assert.Same(t, srv.serviceScope, srv.requestScope(context.Background()))
+
+	srv.startScenario(&godog.Scenario{Id: "first"}, nil)
+
+	first := srv.scope("first")
+
+	assert.NotSame(t, srv.serviceScope, first)
+
+	// The only running scenario gets the requests without the header.
+	assert.Same(t, first, srv.requestScope(context.Background()))
+	assert.Same(t, first, srv.requestScope(withScenario("first")))
+
+	srv.startScenario(&godog.Scenario{Id: "second"}, nil)
+
+	second := srv.scope("second")
+
+	assert.Same(t, second, srv.requestScope(withScenario("second")))
+	assert.Same(t, srv.serviceScope, srv.requestScope(context.Background()))
+	assert.Same(t, srv.serviceScope, srv.requestScope(withScenario("unknown")))
+
+	srv.endScenario("first")
+	srv.endScenario("second")
+
+	assert.Same(t, srv.serviceScope, srv.scope("first"))
+}
+
+func TestServiceScope_Expect(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer("item-service", 42, WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)))
+	defer srv.Close() // nolint: errcheck
+
+	srv.startScenario(&godog.Scenario{Id: "first"}, nil)
+
+	sc := srv.scope("first")
+
+	_, err := sc.expect("/grpctest.ItemService/GetItem", 1, nil)
+	assert.NoError(t, err)
+
+	_, err = sc.expect("/grpctest.ItemService/NotFound", 1, nil)
+	assert.EqualError(t, err, "grpc method not found: /grpctest.ItemService/NotFound")
+
+	assert.Len(t, sc.planner.Remain(), 1)
+	assert.Empty(t, srv.planner.Remain())
+}
+
+func TestWrappedServer_EndScenario(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer("item-service", 42, WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)))
+	defer srv.Close() // nolint: errcheck
+
+	_, err := srv.expect("/grpctest.ItemService/GetItem", 1, nil)
+	assert.NoError(t, err)
+
+	srv.state.Set("created")
+
+	srv.startScenario(&godog.Scenario{Id: "first"}, nil)
+	srv.startScenario(&godog.Scenario{Id: "second"}, nil)
+
+	// The default scope is kept while a scenario runs.
+	srv.endScenario("first")
+
+	assert.Len(t, srv.planner.Remain(), 1)
+
+	srv.endScenario("second")
+
+	assert.Empty(t, srv.planner.Remain())
+	assert.Equal(t, InitialServiceState, srv.state.Current())
+}
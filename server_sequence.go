@@ -0,0 +1,160 @@
+package grpcsteps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cucumber/godog"
+	"go.nhat.io/grpcmock/streamer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// sequencedResponse is one of the responses that the service returns in order.
+type sequencedResponse struct {
+	code    codes.Code
+	message string
+	payload string
+}
+
+// responseSequence returns the responses in order. Once the sequence is exhausted, the last response is repeated.
+type responseSequence struct {
+	responses []sequencedResponse
+	calls     int
+
+	mu sync.Mutex
+}
+
+func (s *responseSequence) next() sequencedResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.calls
+	if i >= len(s.responses) {
+		i = len(s.responses) - 1
+	}
+
+	s.calls++
+
+	return s.responses[i]
+}
+
+func (s *responseSequence) handleUnary(context.Context, interface{}) (interface{}, error) {
+	r := s.next()
+
+	if r.code != codes.OK {
+		return nil, status.Error(r.code, r.message)
+	}
+
+	if r.payload == "" {
+		return "{}", nil
+	}
+
+	return r.payload, nil
+}
+
+func (s *responseSequence) handleClientStream(ctx context.Context, _ grpc.ServerStream) (interface{}, error) {
+	return s.handleUnary(ctx, nil)
+}
+
+func (s *responseSequence) handleServerStream(_ context.Context, _ interface{}, stream grpc.ServerStream) error {
+	r := s.next()
+
+	if r.code != codes.OK {
+		return status.Error(r.code, r.message)
+	}
+
+	if r.payload == "" {
+		return nil
+	}
+
+	var messages []json.RawMessage
+
+	if err := json.Unmarshal([]byte(r.payload), &messages); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	ss, ok := stream.(*streamer.ServerStreamer)
+	if !ok {
+		return status.Errorf(codes.Internal, "could not find the response type of the stream %T", stream)
+	}
+
+	for _, m := range messages {
+		msg := newOutputMessage(stream, ss.OutputType()).(proto.Message) // nolint: errcheck
+
+		if err := protojson.Unmarshal(m, msg); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newResponseSequence(responses []sequencedResponse) *responseSequence {
+	return &responseSequence{
+		responses: responses,
+	}
+}
+
+// toSequencedResponses reads the responses from a table. The first column is the status code, the second one is the
+// payload file when the code is OK, or the error message otherwise.
+func toSequencedResponses(table *godog.Table) ([]sequencedResponse, error) {
+	rows := table.Rows
+
+	if len(rows) > 0 && len(rows[0].Cells) > 0 && strings.EqualFold(rows[0].Cells[0].Value, "code") {
+		rows = rows[1:]
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: there is no response", ErrInvalidResponseSequence)
+	}
+
+	result := make([]sequencedResponse, 0, len(rows))
+
+	for i, row := range rows {
+		if len(row.Cells) == 0 || len(row.Cells) > 2 {
+			return nil, fmt.Errorf("%w: row %d must have 1 or 2 columns", ErrInvalidResponseSequence, i+1)
+		}
+
+		code, err := toStatusCode(strings.TrimSpace(row.Cells[0].Value))
+		if err != nil {
+			return nil, err
+		}
+
+		var value string
+
+		if len(row.Cells) > 1 {
+			value = strings.TrimSpace(row.Cells[1].Value)
+		}
+
+		r := sequencedResponse{code: code}
+
+		switch {
+		case code != codes.OK:
+			r.message = value
+
+		case value != "":
+			payload, err := os.ReadFile(value) // nolint: gosec
+			if err != nil {
+				return nil, err
+			}
+
+			r.payload = string(payload)
+		}
+
+		result = append(result, r)
+	}
+
+	return result, nil
+}
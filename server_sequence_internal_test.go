@@ -0,0 +1,107 @@
+package grpcsteps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cucumber/godog"
+	messages "github.com/cucumber/messages/go/v21"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func newTable(rows ...[]string) *godog.Table {
+	t := &godog.Table{}
+
+	for _, r := range rows {
+		row := &messages.PickleTableRow{}
+
+		for _, c := range r {
+			row.Cells = append(row.Cells, &messages.PickleTableCell{Value: c})
+		}
+
+		t.Rows = append(t.Rows, row)
+	}
+
+	return t
+}
+
+func TestToSequencedResponses(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		table          *godog.Table
+		expectedResult []sequencedResponse
+		expectedError  string
+	}{
+		{
+			scenario:      "no response",
+			table:         newTable([]string{"code", "payload"}),
+			expectedError: `invalid response sequence: there is no response`,
+		},
+		{
+			scenario:      "too many columns",
+			table:         newTable([]string{"OK", "", ""}),
+			expectedError: `invalid response sequence: row 1 must have 1 or 2 columns`,
+		},
+		{
+			scenario:      "invalid code",
+			table:         newTable([]string{"not a code"}),
+			expectedError: `invalid code: "\"NOT A CODE\""`,
+		},
+		{
+			scenario:      "file not found",
+			table:         newTable([]string{"OK", "not_found"}),
+			expectedError: `open not_found: no such file or directory`,
+		},
+		{
+			scenario: "success",
+			table: newTable(
+				[]string{"Unavailable"},
+				[]string{"Internal", "Internal Server Error"},
+				[]string{"OK", ""},
+				[]string{"OK", "resources/fixtures/response-create-items.json"},
+			),
+			expectedResult: []sequencedResponse{
+				{code: codes.Unavailable},
+				{code: codes.Internal, message: "Internal Server Error"},
+				{code: codes.OK},
+				{code: codes.OK, payload: "{\n    \"num_items\": 3\n}\n"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := toSequencedResponses(tc.table)
+
+			assert.Equal(t, tc.expectedResult, result)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+type unknownServerStream struct {
+	grpc.ServerStream
+}
+
+func TestResponseSequence_HandleServerStream_UnknownStream(t *testing.T) {
+	t.Parallel()
+
+	s := newResponseSequence([]sequencedResponse{{code: codes.OK, payload: `[{"id": 42}]`}})
+
+	err := s.handleServerStream(context.Background(), nil, unknownServerStream{})
+
+	assert.EqualError(t, err, "rpc error: code = Internal desc = could not find the response type of the stream grpcsteps.unknownServerStream")
+}
@@ -0,0 +1,229 @@
+package grpcsteps
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cucumber/godog"
+	"go.nhat.io/grpcmock/planner"
+	"go.nhat.io/grpcmock/service"
+)
+
+// InitialServiceState is the state of a service before any transition.
+const InitialServiceState = "Started"
+
+type stateTransition struct {
+	when *string
+	next *string
+}
+
+// serviceState keeps the current scenario state of a service and the state transitions of its expectations.
+type serviceState struct {
+	current     string
+	transitions map[interface{}]*stateTransition
+
+	mu sync.Mutex
+}
+
+func (s *serviceState) Current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.current
+}
+
+func (s *serviceState) Set(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = state
+}
+
+func (s *serviceState) transition(expected interface{}) *stateTransition {
+	t, ok := s.transitions[expected]
+	if !ok {
+		t = &stateTransition{}
+		s.transitions[expected] = t
+	}
+
+	return t
+}
+
+func (s *serviceState) whenInState(expected interface{}, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.transition(expected).when = &state
+}
+
+func (s *serviceState) moveToState(expected interface{}, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.transition(expected).next = &state
+}
+
+// requiredState returns the state that the expectation requires, if any.
+func (s *serviceState) requiredState(expected interface{}) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.transitions[expected]
+	if !ok || t.when == nil {
+		return "", false
+	}
+
+	return *t.when, true
+}
+
+// fulfill moves the service to the next state of the expectation, if any.
+func (s *serviceState) fulfill(expected interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.transitions[expected]; ok && t.next != nil {
+		s.current = *t.next
+	}
+}
+
+func (s *serviceState) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = InitialServiceState
+	s.transitions = make(map[interface{}]*stateTransition)
+}
+
+func newServiceState() *serviceState {
+	s := &serviceState{}
+
+	s.Reset()
+
+	return s
+}
+
+var _ planner.Planner = (*statefulPlanner)(nil)
+
+// statefulPlanner matches the expectations that require the current state of the service first, in any order. If none of
+// them matches, the request is matched against the other expectations sequentially. The requests that do not match are
+// handled by the first fallback that accepts them, if any.
+type statefulPlanner struct {
+	scenario     *godog.Scenario
+	state        *serviceState
+	headers      *headerMatcher
+	fallbacks    []fallback
+	expectations []planner.Expectation
+
+	mu sync.Mutex
+}
+
+func (p *statefulPlanner) IsEmpty() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.expectations) == 0 && len(p.fallbacks) == 0
+}
+
+func (p *statefulPlanner) Expect(expect planner.Expectation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expectations = append(p.expectations, expect)
+}
+
+func (p *statefulPlanner) Plan(ctx context.Context, req service.Method, in interface{}) (planner.Expectation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	expected, err := p.plan(ctx, req, in)
+	if err == nil {
+		return expected, nil
+	}
+
+	for _, f := range p.fallbacks {
+		if expected, ok := f.expectation(p.scenario, req, in); ok {
+			return expected, nil
+		}
+	}
+
+	return nil, err
+}
+
+func (p *statefulPlanner) plan(ctx context.Context, req service.Method, in interface{}) (planner.Expectation, error) {
+	current := p.state.Current()
+
+	for i, expected := range p.expectations {
+		state, ok := p.state.requiredState(expected)
+		if !ok || state != current {
+			continue
+		}
+
+		if err := p.match(ctx, expected, req, in); err == nil {
+			return p.take(i), nil
+		}
+	}
+
+	for i, expected := range p.expectations {
+		if _, ok := p.state.requiredState(expected); ok {
+			continue
+		}
+
+		if err := p.match(ctx, expected, req, in); err != nil {
+			return nil, err
+		}
+
+		return p.take(i), nil
+	}
+
+	return nil, planner.UnexpectedRequestError(req, in)
+}
+
+func (p *statefulPlanner) match(ctx context.Context, expected planner.Expectation, req service.Method, in interface{}) error {
+	if err := planner.MatchRequest(ctx, expected, req, in); err != nil {
+		return err
+	}
+
+	return p.headers.match(ctx, expected, req, in)
+}
+
+func (p *statefulPlanner) take(i int) planner.Expectation {
+	expected := p.expectations[i]
+
+	p.state.fulfill(expected)
+
+	if t := expected.RemainTimes(); t == planner.UnlimitedTimes || t > 1 {
+		return expected
+	}
+
+	p.expectations = append(p.expectations[:i:i], p.expectations[i+1:]...)
+
+	return expected
+}
+
+func (p *statefulPlanner) Remain() []planner.Expectation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.expectations
+}
+
+func (p *statefulPlanner) addFallback(f fallback) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fallbacks = append(p.fallbacks, f)
+}
+
+func (p *statefulPlanner) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expectations = nil
+}
+
+func newStatefulPlanner(state *serviceState, headers *headerMatcher) *statefulPlanner {
+	return &statefulPlanner{
+		state:   state,
+		headers: headers,
+	}
+}
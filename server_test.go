@@ -4,20 +4,39 @@
 package grpcsteps_test
 
 import (
+	"compress/flate"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/cucumber/godog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.nhat.io/grpcmock"
+	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/godogx/grpcsteps"
 	"github.com/godogx/grpcsteps/internal/grpctest"
+	testSrv "github.com/godogx/grpcsteps/internal/test/grpctest"
 )
 
 func TestExternalServiceManager_Success(t *testing.T) {
@@ -26,6 +45,338 @@ func TestExternalServiceManager_Success(t *testing.T) {
 	runServerTest(t, "Success")
 }
 
+func TestExternalServiceManager_State(t *testing.T) {
+	t.Parallel()
+
+	runServerTest(t, "State")
+}
+
+func TestExternalServiceManager_Sequence(t *testing.T) {
+	t.Parallel()
+
+	runServerTest(t, "Sequence")
+}
+
+func TestExternalServiceManager_Fault(t *testing.T) {
+	t.Parallel()
+
+	runServerTest(t, "Fault")
+}
+
+func TestExternalServiceManager_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	runServerTestInMemory(t, "Concurrency", []grpcsteps.ExternalServiceOption{
+		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+	}, concurrency(4))
+}
+
+func TestExternalServiceManager_InMemory(t *testing.T) {
+	t.Parallel()
+
+	runServerTestInMemory(t, "Success", []grpcsteps.ExternalServiceOption{
+		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+	})
+}
+
+func TestExternalServiceManager_Health(t *testing.T) {
+	t.Parallel()
+
+	runServerTestWithServiceOptions(t, "Health", []grpcsteps.ExternalServiceOption{
+		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+		grpcsteps.WithHealthService(),
+	})
+}
+
+func TestExternalServiceManager_Compression(t *testing.T) {
+	t.Parallel()
+
+	runServerTest(t, "Compression")
+}
+
+func TestExternalServiceManager_Header(t *testing.T) {
+	t.Parallel()
+
+	runServerTest(t, "Header")
+}
+
+func TestExternalServiceManager_Deadline(t *testing.T) {
+	t.Parallel()
+
+	runServerTest(t, "Deadline")
+}
+
+func TestExternalServiceManager_HeaderPropagation(t *testing.T) {
+	t.Parallel()
+
+	runServerTest(t, "HeaderPropagation")
+}
+
+func TestExternalServiceManager_Table(t *testing.T) {
+	t.Parallel()
+
+	runServerTest(t, "Table")
+}
+
+func TestExternalServiceManager_TableFromProto(t *testing.T) {
+	t.Parallel()
+
+	runServerTestWithServiceOptions(t, "Table", []grpcsteps.ExternalServiceOption{
+		grpcsteps.RegisterServiceFromProto("resources/protobuf/service.proto"),
+	})
+}
+
+func TestExternalServiceManager_Proto(t *testing.T) {
+	t.Parallel()
+
+	runServerTestWithServiceOptions(t, "Success", []grpcsteps.ExternalServiceOption{
+		grpcsteps.RegisterServiceFromProto("resources/protobuf/service.proto"),
+	})
+}
+
+func TestExternalServiceManager_AddServiceFromProto(t *testing.T) {
+	t.Parallel()
+
+	runServerTestWithService(t, "Success", func(m *grpcsteps.ExternalServiceManager, l net.Listener) {
+		m.AddServiceFromProto("item-service", "resources/protobuf/service.proto", grpcmock.WithListener(l))
+	})
+}
+
+func TestExternalServiceManager_DescriptorSet(t *testing.T) {
+	t.Parallel()
+
+	runServerTestWithServiceOptions(t, "Success", []grpcsteps.ExternalServiceOption{
+		grpcsteps.RegisterServiceFromDescriptorSet(writeDescriptorSet(t)),
+	})
+}
+
+func TestExternalServiceManager_AddServiceFromDescriptorSet(t *testing.T) {
+	t.Parallel()
+
+	file := writeDescriptorSet(t)
+
+	runServerTestWithService(t, "Success", func(m *grpcsteps.ExternalServiceManager, l net.Listener) {
+		m.AddServiceFromDescriptorSet("item-service", file, grpcmock.WithListener(l))
+	})
+}
+
+func TestExternalServiceManager_Upstream(t *testing.T) {
+	t.Parallel()
+
+	runServerTestWithServiceOptions(t, "Upstream", []grpcsteps.ExternalServiceOption{
+		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+		grpcsteps.WithUpstream("bufnet",
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(startUpstream(t)),
+		),
+	})
+}
+
+func TestExternalServiceManager_Record(t *testing.T) {
+	t.Parallel()
+
+	const expectedDir = "features/server/recorded"
+
+	dir := t.TempDir()
+
+	runServerTestWithServiceOptions(t, "Record", []grpcsteps.ExternalServiceOption{
+		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+		grpcsteps.WithRecordFrom("bufnet", dir,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(startUpstream(t)),
+		),
+	}, noRandomize())
+
+	files, err := os.ReadDir(filepath.Join(expectedDir, "item-service"))
+	require.NoError(t, err)
+
+	for _, f := range files {
+		expected, err := os.ReadFile(filepath.Join(expectedDir, "item-service", f.Name()))
+		require.NoError(t, err)
+
+		actual, err := os.ReadFile(filepath.Join(dir, "item-service", f.Name()))
+		require.NoError(t, err)
+
+		assert.Equal(t, string(expected), string(actual), f.Name())
+	}
+
+	expected, err := os.ReadFile(filepath.Join(expectedDir, "item-service.feature"))
+	require.NoError(t, err)
+
+	actual, err := os.ReadFile(filepath.Join(dir, "item-service.feature"))
+	require.NoError(t, err)
+
+	assert.Equal(t, string(expected), strings.ReplaceAll(string(actual), dir, expectedDir))
+}
+
+func TestExternalServiceManager_Replay(t *testing.T) {
+	t.Parallel()
+
+	runServerTestWithServiceOptions(t, "Record", []grpcsteps.ExternalServiceOption{
+		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+		grpcsteps.WithReplayFrom("features/server/recorded"),
+	})
+}
+
+func TestExternalServiceManager_RegisterContextWithClient(t *testing.T) {
+	t.Parallel()
+
+	srv := grpcsteps.NewExternalServiceManager(grpcsteps.WithInMemoryTransport())
+	srv.AddService("item-service", grpcmock.RegisterService(grpctest.RegisterItemServiceServer))
+
+	t.Cleanup(srv.Close)
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer,
+			grpcsteps.WithExternalService(srv, "item-service"),
+		),
+	)
+
+	// The shared steps are not ambiguous in the strict mode, whatever the order of the registrations.
+	runSuite(t,
+		initScenario(srv.RegisterContext, c.RegisterContext),
+		featureFiles("features/server/Header.feature", "features/server/Deadline.feature"),
+	)
+}
+
+func TestExternalServiceManager_Transcript(t *testing.T) {
+	t.Parallel()
+
+	srv := grpcsteps.NewExternalServiceManager(grpcsteps.WithInMemoryTransport())
+	srv.AddService("item-service", grpcmock.RegisterService(grpctest.RegisterItemServiceServer))
+
+	t.Cleanup(srv.Close)
+
+	var out strings.Builder
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer,
+			grpcsteps.WithExternalService(srv, "item-service"),
+		),
+		grpcsteps.WithTranscriptOutput(&out),
+	)
+
+	tt := &testT{}
+
+	runSuite(tt,
+		format("cucumber"),
+		noRandomize(),
+		initScenario(c.RegisterContext, srv.RegisterContext),
+		featureFiles("features/server/Transcript.feature"),
+	)
+
+	require.Error(t, tt.error)
+
+	// Only the failed scenario has a transcript.
+	transcript := out.String()
+
+	assert.Equal(t, 1, strings.Count(transcript, "grpc transcript of scenario"))
+	assert.Contains(t, transcript, `grpc transcript of scenario "Unexpected response":`)
+	assert.Contains(t, transcript, "1. item-service <- /grpctest.ItemService/GetItem (bufconn) OK in ")
+	assert.Contains(t, transcript, "2. client -> /grpctest.ItemService/GetItem (item-service) OK in ")
+	assert.Contains(t, transcript, `locale="en-US"`)
+	assert.Contains(t, transcript, `request: {"id":42}`)
+	assert.Contains(t, transcript, `response: {"id":42,"name":"Item #42"}`)
+	assert.Contains(t, transcript, `response header: content-type="application/grpc"`)
+
+	// The transcript is attached to the failed step of the cucumber report.
+	var report []struct {
+		Elements []struct {
+			Name  string `json:"name"`
+			Steps []struct {
+				Embeddings []struct {
+					Name     string `json:"name"`
+					MimeType string `json:"mime_type"`
+					Data     []byte `json:"data"`
+				} `json:"embeddings"`
+			} `json:"steps"`
+		} `json:"elements"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(tt.error.Error()), &report))
+	require.Len(t, report, 1)
+	require.Len(t, report[0].Elements, 2)
+
+	var attached []string
+
+	for _, sc := range report[0].Elements {
+		for _, step := range sc.Steps {
+			for _, e := range step.Embeddings {
+				assert.Equal(t, "grpc-transcript.json", e.Name)
+				assert.Equal(t, "application/json", e.MimeType)
+
+				attached = append(attached, sc.Name)
+
+				var entries []map[string]interface{}
+
+				require.NoError(t, json.Unmarshal(e.Data, &entries))
+				require.Len(t, entries, 2)
+
+				assert.Equal(t, "item-service", entries[0]["source"])
+				assert.Equal(t, "client", entries[1]["source"])
+				assert.Equal(t, "/grpctest.ItemService/GetItem", entries[1]["method"])
+				assert.Equal(t, "OK", entries[1]["code"])
+				assert.Equal(t, map[string]interface{}{"id": 42.0, "name": "Item #42"}, entries[1]["response"])
+			}
+		}
+	}
+
+	assert.Equal(t, []string{"Unexpected response"}, attached)
+}
+
+func TestExternalServiceManager_Trace(t *testing.T) {
+	t.Parallel()
+
+	srv := grpcsteps.NewExternalServiceManager()
+	addr := srv.AddService("item-service", grpcmock.RegisterService(grpctest.RegisterItemServiceServer))
+
+	t.Cleanup(srv.Close)
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close() // nolint: errcheck
+	})
+
+	var dial func(ctx context.Context, addr string) (net.Conn, error)
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer,
+			grpcsteps.WithDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+			grpcsteps.WithDialOption(grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return dial(ctx, addr)
+			})),
+		),
+		grpcsteps.WithTracing(),
+	)
+
+	tracer := c.TracerProvider().Tracer("item-app")
+
+	// The application under test finds the items in the item-service, in the trace of the request, with its tenant.
+	dial = testSrv.StartServer(t, testSrv.GetItem(func(ctx context.Context, req *grpctest.GetItemRequest) (*grpctest.Item, error) {
+		in, _ := metadata.FromIncomingContext(ctx)
+		ctx = propagation.TraceContext{}.Extract(ctx, metadataCarrier(in))
+
+		ctx, span := tracer.Start(ctx, "find item")
+		defer span.End()
+
+		header := metadata.MD{}
+		propagation.TraceContext{}.Inject(ctx, metadataCarrier(header))
+
+		if tenant := in.Get("x-tenant-id"); len(tenant) > 0 {
+			header.Set("x-tenant-id", tenant...)
+		}
+
+		return grpctest.NewItemServiceClient(conn).GetItem(metadata.NewOutgoingContext(ctx, header), req)
+	}))
+
+	runSuite(t,
+		initScenario(c.RegisterContext, srv.RegisterContext),
+		featureFiles("features/server/Trace.feature"),
+	)
+}
+
 func TestExternalServiceManager_Error(t *testing.T) {
 	t.Parallel()
 
@@ -190,33 +541,149 @@ func TestExternalServiceManager_Error(t *testing.T) {
 	}
 }
 
+func startUpstream(t *testing.T) func(context.Context, string) (net.Conn, error) {
+	t.Helper()
+
+	return testSrv.StartServer(t,
+		testSrv.GetItem(func(_ context.Context, req *grpctest.GetItemRequest) (*grpctest.Item, error) {
+			if req.GetId() != 43 {
+				return nil, status.Errorf(codes.NotFound, "Item #%d not found", req.GetId())
+			}
+
+			return &grpctest.Item{Id: req.GetId(), Name: "Upstream Item #43"}, nil
+		}),
+		testSrv.ListItems(func(_ *grpctest.ListItemsRequest, srv grpctest.ItemService_ListItemsServer) error {
+			for i := int32(40); i < 42; i++ {
+				if err := srv.Send(&grpctest.Item{Id: i, Name: fmt.Sprintf("Upstream Item #%d", i)}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}),
+		testSrv.CreateItems(func(srv grpctest.ItemService_CreateItemsServer) error {
+			var numItems int64
+
+			for {
+				_, err := srv.Recv()
+				if errors.Is(err, io.EOF) {
+					return srv.SendAndClose(&grpctest.CreateItemsResponse{NumItems: numItems})
+				}
+
+				if err != nil {
+					return err
+				}
+
+				numItems++
+			}
+		}),
+	)
+}
+
+func writeDescriptorSet(t *testing.T) string {
+	t.Helper()
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto),
+			protodesc.ToFileDescriptorProto(grpctest.File_resources_protobuf_service_proto),
+		},
+	}
+
+	data, err := proto.Marshal(set)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "service.protoset")
+
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+
+	return file
+}
+
 func runServerTest(
 	t suiteT,
 	scenario string,
 	opts ...suiteOption,
+) {
+	runServerTestWithServiceOptions(t, scenario, []grpcsteps.ExternalServiceOption{
+		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+	}, opts...)
+}
+
+func runServerTestWithServiceOptions(
+	t suiteT,
+	scenario string,
+	svcOpts []grpcsteps.ExternalServiceOption,
+	opts ...suiteOption,
+) {
+	runServerTestWithService(t, scenario, func(m *grpcsteps.ExternalServiceManager, l net.Listener) {
+		svcOpts = append([]grpcsteps.ExternalServiceOption{
+			grpcsteps.WithServerOptions(grpcmock.WithListener(l)),
+		}, svcOpts...)
+
+		m.AddServiceWithOptions("item-service", svcOpts...)
+	}, opts...)
+}
+
+func runServerTestWithService(
+	t suiteT,
+	scenario string,
+	addService func(m *grpcsteps.ExternalServiceManager, l net.Listener),
+	opts ...suiteOption,
 ) {
 	buf := bufconn.Listen(2048 * 2048)
 
 	t.Logf("[%s]: starting grpc server", scenario)
 
 	srv := grpcsteps.NewExternalServiceManager()
-	srv.AddService("item-service",
-		grpcmock.WithListener(buf),
-		grpcmock.RegisterService(grpctest.RegisterItemServiceServer),
+	addService(srv, buf)
+
+	dialOpts := grpcsteps.WithDialOptions(
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return buf.Dial()
+		}),
 	)
 
 	c := grpcsteps.NewClient(
-		grpcsteps.WithDefaultServiceOptions(
-			grpcsteps.WithDialOptions(
-				grpc.WithTransportCredentials(insecure.NewCredentials()),
-				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
-					return buf.Dial()
-				}),
-			),
-		),
+		grpcsteps.WithDefaultServiceOptions(dialOpts),
 		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer),
+		grpcsteps.RegisterService(healthpb.RegisterHealthServer),
+	)
+
+	runServerSuite(t, scenario, srv, c, opts...)
+}
+
+func runServerTestInMemory(
+	t suiteT,
+	scenario string,
+	svcOpts []grpcsteps.ExternalServiceOption,
+	opts ...suiteOption,
+) {
+	t.Logf("[%s]: starting grpc server", scenario)
+
+	srv := grpcsteps.NewExternalServiceManager(grpcsteps.WithInMemoryTransport())
+	srv.AddServiceWithOptions("item-service", svcOpts...)
+
+	c := grpcsteps.NewClient(
+		grpcsteps.RegisterService(grpctest.RegisterItemServiceServer,
+			grpcsteps.WithExternalService(srv, "item-service"),
+		),
+		grpcsteps.RegisterService(healthpb.RegisterHealthServer,
+			grpcsteps.WithExternalService(srv, "item-service"),
+		),
 	)
 
+	runServerSuite(t, scenario, srv, c, opts...)
+}
+
+func runServerSuite(
+	t suiteT,
+	scenario string,
+	srv *grpcsteps.ExternalServiceManager,
+	c *grpcsteps.Client,
+	opts ...suiteOption,
+) {
 	opts = append(opts,
 		afterSuite(func() {
 			t.Logf("[%s]: shutting down grpc server", scenario)
@@ -249,3 +716,46 @@ func runServerTest(
 
 	runSuite(t, opts...)
 }
+
+func init() { // nolint: gochecknoinits
+	// A custom compressor that the clients and the mocked services share.
+	encoding.RegisterCompressor(deflateCompressor{})
+}
+
+type deflateCompressor struct{}
+
+func (deflateCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.DefaultCompression)
+}
+
+func (deflateCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return flate.NewReader(r), nil
+}
+
+func (deflateCompressor) Name() string {
+	return "deflate"
+}
+
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
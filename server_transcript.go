@@ -0,0 +1,109 @@
+package grpcsteps
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.nhat.io/grpcmock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// transcriptServerOptions records the requests that a mocked service receives in the transcript of the scenario that
+// sends them, including the ones that fail because of the injected faults.
+func transcriptServerOptions(id string, addr func() string, scope func(ctx context.Context) *serviceScope) []grpcmock.ServerOption {
+	newEntry := func(ctx context.Context, method string, err error, start time.Time) transcriptEntry {
+		header, _ := metadata.FromIncomingContext(ctx)
+
+		e := newTranscriptEntry(id, method, header, err, time.Since(start))
+		e.Address = addr()
+
+		return e
+	}
+
+	return []grpcmock.ServerOption{
+		grpcmock.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			start := time.Now()
+
+			resp, err := handler(ctx, req)
+
+			e := newEntry(ctx, info.FullMethod, err, start)
+			e.Request = transcriptPayload(req)
+
+			if err == nil {
+				e.Response = transcriptPayload(resp)
+			}
+
+			scope(ctx).transcript.Record(e)
+
+			return resp, err
+		}),
+		grpcmock.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			start := time.Now()
+			s := &transcriptServerStream{ServerStream: ss}
+
+			err := handler(srv, s)
+
+			e := newEntry(ss.Context(), info.FullMethod, err, start)
+			e.Request = s.payload(s.requests, info.IsClientStream)
+			e.Response = s.payload(s.responses, info.IsServerStream)
+
+			scope(ss.Context()).transcript.Record(e)
+
+			return err
+		}),
+	}
+}
+
+// transcriptServerStream keeps the messages that a mocked service receives and sends.
+type transcriptServerStream struct {
+	grpc.ServerStream
+
+	requests  []interface{}
+	responses []interface{}
+
+	mu sync.Mutex
+}
+
+func (s *transcriptServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, m)
+
+	return nil
+}
+
+func (s *transcriptServerStream) SendMsg(m interface{}) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = append(s.responses, m)
+
+	return nil
+}
+
+// payload returns the messages of a stream, or the only message if the side is not streamed.
+func (s *transcriptServerStream) payload(messages []interface{}, streamed bool) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if streamed {
+		return transcriptPayload(messages)
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return transcriptPayload(messages[0])
+}
@@ -0,0 +1,335 @@
+package grpcsteps
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/cucumber/godog"
+	xmatcher "go.nhat.io/grpcmock/matcher"
+	"go.nhat.io/grpcmock/planner"
+	xreflect "go.nhat.io/grpcmock/reflect"
+	"go.nhat.io/grpcmock/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// WithUpstream forwards the requests that do not match any expectation to the upstream service and relays its responses.
+// If there is no dial option, the connection is insecure.
+//
+//	m.AddServiceWithOptions("item-service",
+//		grpcsteps.WithServerOptions(grpcmock.RegisterService(grpctest.RegisterItemServiceServer)),
+//		grpcsteps.WithUpstream("localhost:9000"),
+//	)
+func WithUpstream(addr string, opts ...grpc.DialOption) ExternalServiceOption {
+	return func(c *serviceConfig) {
+		c.upstreamAddr = addr
+		c.upstreamDialOptions = opts
+	}
+}
+
+// fallback handles the requests that do not match any expectation of the scenario, nil for the default scope.
+type fallback interface {
+	expectation(sc *godog.Scenario, svc service.Method, in interface{}) (planner.Expectation, bool)
+}
+
+var _ fallback = (*upstream)(nil)
+
+// upstream is a real service that receives the requests that the mocked service does not expect.
+type upstream struct {
+	conn     *grpc.ClientConn
+	recorder *recorder
+}
+
+func (u *upstream) expectation(sc *godog.Scenario, svc service.Method, _ interface{}) (planner.Expectation, bool) {
+	return &upstreamExpectation{
+		fallbackExpectation: fallbackExpectation{svc: svc},
+		upstream:            u,
+		scenario:            sc,
+	}, true
+}
+
+func (u *upstream) Close() error {
+	return u.conn.Close()
+}
+
+func newUpstream(addr string, opts ...grpc.DialOption) (*upstream, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upstream{conn: conn}, nil
+}
+
+// fallbackExpectation is an expectation that matches any request of a method, as many times as it is requested.
+type fallbackExpectation struct {
+	svc service.Method
+
+	fulfilledTimes uint
+	mu             sync.Mutex
+}
+
+func (e *fallbackExpectation) ServiceMethod() service.Method {
+	return e.svc
+}
+
+func (e *fallbackExpectation) HeaderMatcher() xmatcher.HeaderMatcher {
+	return nil
+}
+
+func (e *fallbackExpectation) PayloadMatcher() *xmatcher.PayloadMatcher {
+	return nil
+}
+
+func (e *fallbackExpectation) RemainTimes() uint {
+	return planner.UnlimitedTimes
+}
+
+func (e *fallbackExpectation) Fulfilled() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.fulfilledTimes++
+}
+
+func (e *fallbackExpectation) FulfilledTimes() uint {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.fulfilledTimes
+}
+
+var _ planner.Expectation = (*upstreamExpectation)(nil)
+
+// upstreamExpectation forwards a request to the upstream service.
+type upstreamExpectation struct {
+	fallbackExpectation
+
+	upstream *upstream
+	scenario *godog.Scenario
+
+	// The messages that are relayed, for recording.
+	requests  []interface{}
+	responses []interface{}
+}
+
+// Handle forwards the request to the upstream service and relays the response. The recording does not change the
+// response, its errors fail the scenario instead.
+func (e *upstreamExpectation) Handle(ctx context.Context, in interface{}, out interface{}) error {
+	err := e.handle(ctx, in, out)
+
+	if e.upstream.recorder != nil {
+		e.upstream.recorder.Record(e.scenario, e.svc, e.requests, e.responses, err)
+	}
+
+	return err
+}
+
+func (e *upstreamExpectation) handle(ctx context.Context, in interface{}, out interface{}) error {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = metadata.NewOutgoingContext(ctx, md.Copy())
+	}
+
+	if service.IsMethodUnary(e.svc.MethodType) {
+		return e.handleUnary(ctx, in, out)
+	}
+
+	isClientStream, isServerStream := service.FromType(e.svc.MethodType)
+
+	upstreamStream, err := e.upstream.conn.NewStream(ctx, &grpc.StreamDesc{
+		StreamName:    e.svc.MethodName,
+		ClientStreams: isClientStream,
+		ServerStreams: isServerStream,
+	}, e.svc.FullName())
+	if err != nil {
+		return err
+	}
+
+	// nolint: exhaustive
+	switch e.svc.MethodType {
+	case service.TypeServerStream:
+		return e.handleServerStream(upstreamStream, in, out.(grpc.ServerStream))
+
+	case service.TypeClientStream:
+		return e.handleClientStream(upstreamStream, in.(grpc.ServerStream), out)
+
+	default:
+		return e.handleBidirectionalStream(upstreamStream, in.(grpc.ServerStream))
+	}
+}
+
+func (e *upstreamExpectation) handleUnary(ctx context.Context, in interface{}, out interface{}) error {
+	var header, trailer metadata.MD
+
+	e.requests = append(e.requests, in)
+
+	err := e.upstream.conn.Invoke(ctx, e.svc.FullName(), in, out, grpc.Header(&header), grpc.Trailer(&trailer))
+
+	_ = grpc.SetHeader(ctx, header)   // nolint: errcheck
+	_ = grpc.SetTrailer(ctx, trailer) // nolint: errcheck
+
+	if err != nil {
+		return err
+	}
+
+	e.responses = append(e.responses, out)
+
+	return nil
+}
+
+func (e *upstreamExpectation) handleServerStream(upstreamStream grpc.ClientStream, in interface{}, downstream grpc.ServerStream) error {
+	e.requests = append(e.requests, in)
+
+	if err := upstreamStream.SendMsg(in); err != nil {
+		return err
+	}
+
+	if err := upstreamStream.CloseSend(); err != nil {
+		return err
+	}
+
+	return e.relayToDownstream(upstreamStream, downstream)
+}
+
+func (e *upstreamExpectation) handleClientStream(upstreamStream grpc.ClientStream, downstream grpc.ServerStream, out interface{}) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	if err := e.relayToUpstream(receiveDownstream(downstream, e.svc.Input, done), upstreamStream, done); err != nil {
+		return err
+	}
+
+	if err := upstreamStream.RecvMsg(out); err != nil {
+		return err
+	}
+
+	e.responses = append(e.responses, out)
+
+	relayHeader(upstreamStream, downstream)
+
+	return downstream.SendMsg(out)
+}
+
+// handleBidirectionalStream relays the messages both ways. The relay to the upstream is waited for on every path, so the
+// relayed requests are complete when they are recorded.
+func (e *upstreamExpectation) handleBidirectionalStream(upstreamStream grpc.ClientStream, downstream grpc.ServerStream) error {
+	done := make(chan struct{})
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- e.relayToUpstream(receiveDownstream(downstream, e.svc.Input, done), upstreamStream, done)
+	}()
+
+	if err := e.relayToDownstream(upstreamStream, downstream); err != nil {
+		// The requests that the downstream sends after the failure are not relayed.
+		close(done)
+		<-errCh
+
+		return err
+	}
+
+	err := <-errCh
+
+	close(done)
+
+	return err
+}
+
+// relayToUpstream relays the received messages to the upstream until the downstream ends or done is closed.
+func (e *upstreamExpectation) relayToUpstream(received <-chan receivedMessage, upstreamStream grpc.ClientStream, done <-chan struct{}) error {
+	for {
+		var r receivedMessage
+
+		select {
+		case r = <-received:
+		case <-done:
+			return nil
+		}
+
+		if r.err != nil {
+			if errors.Is(r.err, io.EOF) {
+				return upstreamStream.CloseSend()
+			}
+
+			return r.err
+		}
+
+		e.requests = append(e.requests, r.msg)
+
+		if err := upstreamStream.SendMsg(r.msg); err != nil {
+			return err
+		}
+	}
+}
+
+// receivedMessage is a message that the downstream sends, or the error that ends it.
+type receivedMessage struct {
+	msg interface{}
+	err error
+}
+
+// receiveDownstream receives the messages of the downstream until it ends or done is closed. Receiving cannot be stopped
+// before the handler returns, so it runs on its own and does not touch the expectation.
+func receiveDownstream(downstream grpc.ServerStream, input interface{}, done <-chan struct{}) <-chan receivedMessage {
+	received := make(chan receivedMessage)
+
+	go func() {
+		for {
+			msg := xreflect.New(input)
+			err := downstream.RecvMsg(msg)
+
+			select {
+			case received <- receivedMessage{msg: msg, err: err}:
+			case <-done:
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return received
+}
+
+func (e *upstreamExpectation) relayToDownstream(upstreamStream grpc.ClientStream, downstream grpc.ServerStream) error {
+	msgType := xreflect.UnwrapType(e.svc.Output)
+
+	for i := 0; ; i++ {
+		msg := newOutputMessage(downstream, msgType)
+
+		if err := upstreamStream.RecvMsg(msg); err != nil {
+			downstream.SetTrailer(upstreamStream.Trailer())
+
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		if i == 0 {
+			relayHeader(upstreamStream, downstream)
+		}
+
+		e.responses = append(e.responses, msg)
+
+		if err := downstream.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func relayHeader(upstreamStream grpc.ClientStream, downstream grpc.ServerStream) {
+	if header, err := upstreamStream.Header(); err == nil {
+		_ = downstream.SetHeader(header) // nolint: errcheck
+	}
+}
@@ -0,0 +1,206 @@
+package grpcsteps
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/grpcmock"
+	"go.nhat.io/grpcmock/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+// testDownstream sends a message, another one once the upstream fails, then blocks until the handler returns, like a
+// client that waits for the responses.
+type testDownstream struct {
+	grpc.ServerStream
+
+	messages []*grpctest.Item
+	failed   chan struct{}
+	returned chan struct{}
+}
+
+func (s *testDownstream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *testDownstream) RecvMsg(m interface{}) error {
+	if len(s.messages) == 0 {
+		<-s.returned
+
+		return context.Canceled
+	}
+
+	if len(s.messages) == 1 {
+		<-s.failed
+	}
+
+	m.(*grpctest.Item).Id = s.messages[0].GetId() // nolint: errcheck
+	s.messages = s.messages[1:]
+
+	return nil
+}
+
+func (s *testDownstream) SendMsg(interface{}) error {
+	return nil
+}
+
+func (s *testDownstream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *testDownstream) SetTrailer(metadata.MD) {}
+
+// testUpstream fails once it receives a message.
+type testUpstream struct {
+	grpc.ClientStream
+
+	sent     chan struct{}
+	sendOnce sync.Once
+	failed   chan struct{}
+}
+
+func (s *testUpstream) SendMsg(interface{}) error {
+	s.sendOnce.Do(func() { close(s.sent) })
+
+	return nil
+}
+
+func (s *testUpstream) RecvMsg(interface{}) error {
+	<-s.sent
+	close(s.failed)
+
+	return status.Error(codes.Unavailable, "upstream is gone")
+}
+
+func (s *testUpstream) Trailer() metadata.MD {
+	return nil
+}
+
+func TestUpstreamExpectation_HandleBidirectionalStreamError(t *testing.T) {
+	t.Parallel()
+
+	e := &upstreamExpectation{
+		fallbackExpectation: fallbackExpectation{svc: service.Method{
+			ServiceName: "grpctest.ItemService",
+			MethodName:  "TransformItems",
+			MethodType:  service.TypeBidirectionalStream,
+			Input:       &grpctest.Item{},
+			Output:      &grpctest.Item{},
+		}},
+	}
+
+	failed := make(chan struct{})
+
+	downstream := &testDownstream{
+		messages: []*grpctest.Item{{Id: 42}, {Id: 43}},
+		failed:   failed,
+		returned: make(chan struct{}),
+	}
+	defer close(downstream.returned)
+
+	err := e.handleBidirectionalStream(&testUpstream{sent: make(chan struct{}), failed: failed}, downstream)
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// The relay is done when the handler returns, the requests do not change while they are recorded.
+	requests := append([]interface{}(nil), e.requests...)
+
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Equal(t, requests, e.requests)
+	assert.Equal(t, &grpctest.Item{Id: 42}, e.requests[0])
+}
+
+// blockingItemService blocks the requests until it is released.
+type blockingItemService struct {
+	grpctest.UnimplementedItemServiceServer
+
+	received chan struct{}
+	release  chan struct{}
+}
+
+func (s *blockingItemService) GetItem(_ context.Context, req *grpctest.GetItemRequest) (*grpctest.Item, error) {
+	s.received <- struct{}{}
+	<-s.release
+
+	return &grpctest.Item{Id: req.GetId()}, nil
+}
+
+func TestUpstreamExpectation_DoesNotBlockOtherScenarios(t *testing.T) {
+	t.Parallel()
+
+	upstreamListener := bufconn.Listen(inMemoryBufferSize)
+	upstreamService := &blockingItemService{received: make(chan struct{}), release: make(chan struct{})}
+
+	upstreamServer := grpc.NewServer()
+	grpctest.RegisterItemServiceServer(upstreamServer, upstreamService)
+
+	go upstreamServer.Serve(upstreamListener) // nolint: errcheck
+
+	t.Cleanup(upstreamServer.Stop)
+
+	l := bufconn.Listen(inMemoryBufferSize)
+
+	srv := newServer("item-service", 42,
+		WithServerOptions(
+			grpcmock.WithListener(l),
+			grpcmock.RegisterService(grpctest.RegisterItemServiceServer),
+		),
+		WithUpstream("bufnet",
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return upstreamListener.DialContext(ctx)
+			}),
+		),
+	)
+
+	t.Cleanup(func() {
+		_ = srv.Close() // nolint: errcheck
+	})
+
+	client := grpctest.NewItemServiceClient(dialInMemory(t, l))
+
+	srv.startScenario(&godog.Scenario{Id: "forwarded"}, nil)
+	srv.startScenario(&godog.Scenario{Id: "mocked"}, nil)
+
+	expected, err := srv.scope("mocked").expect("/grpctest.ItemService/GetItem", 1, nil)
+	require.NoError(t, err)
+
+	expected.Return(`{"id": 43}`)
+
+	forwarded := make(chan error, 1)
+
+	go func() {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), ScenarioHeader, "forwarded")
+
+		_, err := client.GetItem(ctx, &grpctest.GetItemRequest{Id: 42})
+		forwarded <- err
+	}()
+
+	<-upstreamService.received
+
+	// The upstream holds the forwarded request, the mocked one is still answered.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	item, err := client.GetItem(metadata.AppendToOutgoingContext(ctx, ScenarioHeader, "mocked"), &grpctest.GetItemRequest{Id: 43})
+	require.NoError(t, err)
+	assert.Equal(t, int32(43), item.GetId())
+
+	close(upstreamService.release)
+
+	require.NoError(t, <-forwarded)
+}
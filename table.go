@@ -0,0 +1,317 @@
+package grpcsteps
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cucumber/godog"
+	xreflect "go.nhat.io/grpcmock/reflect"
+	"go.nhat.io/grpcmock/service"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const ignoreDiff = "<ignore-diff>"
+
+var matchFieldPathSegment = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(?:\[([0-9]+)])?$`)
+
+// messageType describes the messages of a side of a method, so the payloads could be built from tables.
+type messageType struct {
+	descriptor protoreflect.MessageDescriptor
+	stream     bool
+}
+
+// newMessageTypes returns the types of the requests and the responses of a method that has generated messages.
+func newMessageTypes(svc service.Method) (messageType, messageType, error) {
+	inDesc, err := messageDescriptorOf(svc.Input)
+	if err != nil {
+		return messageType{}, messageType{}, err
+	}
+
+	outDesc, err := messageDescriptorOf(svc.Output)
+	if err != nil {
+		return messageType{}, messageType{}, err
+	}
+
+	in, out := methodMessageTypes(svc.MethodType, inDesc, outDesc)
+
+	return in, out, nil
+}
+
+// methodMessageTypes returns the types of the requests and the responses of a method.
+func methodMessageTypes(t service.Type, in, out protoreflect.MessageDescriptor) (messageType, messageType) {
+	return messageType{descriptor: in, stream: service.IsMethodClientStream(t) || service.IsMethodBidirectionalStream(t)},
+		messageType{descriptor: out, stream: service.IsMethodServerStream(t) || service.IsMethodBidirectionalStream(t)}
+}
+
+func messageDescriptorOf(v interface{}) (protoreflect.MessageDescriptor, error) {
+	m, ok := reflect.New(xreflect.UnwrapType(v)).Interface().(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not a proto message", ErrInvalidTable, v)
+	}
+
+	return m.ProtoReflect().Descriptor(), nil
+}
+
+// tablePayload builds the JSON payload of the messages from a table. A message is a vertical table of field paths and
+// values, for example:
+//
+//	| id            | 42  |
+//	| items[0].name | foo |
+//
+// A stream is a horizontal table with the field paths in the first row, and a message in each of the other rows. The
+// empty cells are left out.
+func tablePayload(t messageType, table *godog.Table) (string, error) {
+	var (
+		payload interface{}
+		err     error
+	)
+
+	if t.stream {
+		payload, err = horizontalTableMessages(t.descriptor, table)
+	} else {
+		payload, err = verticalTableMessage(t.descriptor, table)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(payload); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func verticalTableMessage(md protoreflect.MessageDescriptor, table *godog.Table) (map[string]interface{}, error) {
+	msg := make(map[string]interface{})
+
+	for i, row := range table.Rows {
+		if len(row.Cells) != 2 {
+			return nil, fmt.Errorf("%w: row %d has %d cells, a field path and a value expected", ErrInvalidTable, i+1, len(row.Cells))
+		}
+
+		if err := setFieldValue(msg, md, row.Cells[0].Value, row.Cells[1].Value); err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
+
+func horizontalTableMessages(md protoreflect.MessageDescriptor, table *godog.Table) ([]map[string]interface{}, error) {
+	if len(table.Rows) == 0 {
+		return nil, fmt.Errorf("%w: the field paths are missing", ErrInvalidTable)
+	}
+
+	paths := table.Rows[0].Cells
+	msgs := make([]map[string]interface{}, 0, len(table.Rows)-1)
+
+	for i, row := range table.Rows[1:] {
+		if len(row.Cells) != len(paths) {
+			return nil, fmt.Errorf("%w: row %d has %d cells, %d expected", ErrInvalidTable, i+2, len(row.Cells), len(paths))
+		}
+
+		msg := make(map[string]interface{})
+
+		for j, cell := range row.Cells {
+			if cell.Value == "" {
+				continue
+			}
+
+			if err := setFieldValue(msg, md, paths[j].Value, cell.Value); err != nil {
+				return nil, err
+			}
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+// setFieldValue sets the value of a field path, like "items[0].name" or "labels.env", in the JSON object of a message.
+func setFieldValue(msg map[string]interface{}, md protoreflect.MessageDescriptor, path, value string) error {
+	return setSegmentsValue(msg, md, path, strings.Split(path, "."), value)
+}
+
+func setSegmentsValue(msg map[string]interface{}, md protoreflect.MessageDescriptor, path string, segments []string, value string) error {
+	m := matchFieldPathSegment.FindStringSubmatch(segments[0])
+	if m == nil {
+		return fmt.Errorf("%w: %q", ErrInvalidFieldPath, path)
+	}
+
+	fd := md.Fields().ByName(protoreflect.Name(m[1]))
+	if fd == nil {
+		fd = md.Fields().ByJSONName(m[1])
+	}
+
+	if fd == nil {
+		return fmt.Errorf("%w: %q in %s", ErrUnknownField, path, md.FullName())
+	}
+
+	name, rest := string(fd.Name()), segments[1:]
+
+	switch {
+	case m[2] != "":
+		if !fd.IsList() {
+			return fmt.Errorf("%w: %q, %s is not a list", ErrInvalidFieldPath, path, fd.Name())
+		}
+
+		index, _ := strconv.Atoi(m[2]) // nolint: errcheck
+
+		list, _ := msg[name].([]interface{}) // nolint: errcheck
+		if index > len(list) {
+			return fmt.Errorf("%w: %q, the next index of %s is %d", ErrInvalidFieldPath, path, fd.Name(), len(list))
+		}
+
+		if index == len(list) {
+			list = append(list, nil)
+		}
+
+		v, err := fieldValue(list[index], fd, path, rest, value)
+		if err != nil {
+			return err
+		}
+
+		list[index] = v
+		msg[name] = list
+
+	case fd.IsMap() && len(rest) > 0:
+		entries, _ := msg[name].(map[string]interface{}) // nolint: errcheck
+		if entries == nil {
+			entries = make(map[string]interface{})
+		}
+
+		v, err := fieldValue(entries[rest[0]], fd.MapValue(), path, rest[1:], value)
+		if err != nil {
+			return err
+		}
+
+		entries[rest[0]] = v
+		msg[name] = entries
+
+	case fd.IsList() || fd.IsMap():
+		if len(rest) > 0 {
+			return fmt.Errorf("%w: %q, %s is a list", ErrInvalidFieldPath, path, fd.Name())
+		}
+
+		// The whole list or map is in JSON.
+		v, err := rawFieldValue(fd, path, value)
+		if err != nil {
+			return err
+		}
+
+		msg[name] = v
+
+	default:
+		v, err := fieldValue(msg[name], fd, path, rest, value)
+		if err != nil {
+			return err
+		}
+
+		msg[name] = v
+	}
+
+	return nil
+}
+
+func fieldValue(current interface{}, fd protoreflect.FieldDescriptor, path string, rest []string, value string) (interface{}, error) {
+	if fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind {
+		if len(rest) > 0 {
+			return nil, fmt.Errorf("%w: %q, %s is not a message", ErrInvalidFieldPath, path, fd.Name())
+		}
+
+		return scalarFieldValue(fd, path, value)
+	}
+
+	if len(rest) == 0 {
+		// The whole message is in JSON.
+		return rawFieldValue(fd, path, value)
+	}
+
+	msg, _ := current.(map[string]interface{}) // nolint: errcheck
+	if msg == nil {
+		msg = make(map[string]interface{})
+	}
+
+	if err := setSegmentsValue(msg, fd.Message(), path, rest, value); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func rawFieldValue(fd protoreflect.FieldDescriptor, path, value string) (interface{}, error) {
+	if value == ignoreDiff {
+		return value, nil
+	}
+
+	if !json.Valid([]byte(value)) {
+		return nil, fmt.Errorf("%w: %q for %q, %s expects JSON", ErrInvalidFieldValue, value, path, fd.Name())
+	}
+
+	return json.RawMessage(value), nil
+}
+
+func scalarFieldValue(fd protoreflect.FieldDescriptor, path, value string) (interface{}, error) {
+	if value == ignoreDiff {
+		return value, nil
+	}
+
+	var err error
+
+	switch fd.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		// Bytes are base64 encoded, like in the JSON payloads.
+		return value, nil
+
+	case protoreflect.BoolKind:
+		var b bool
+
+		if b, err = strconv.ParseBool(value); err == nil {
+			return b, nil
+		}
+
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value)); ev != nil {
+			return json.Number(strconv.Itoa(int(ev.Number()))), nil
+		}
+
+		_, err = strconv.ParseInt(value, 10, 32)
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		_, err = strconv.ParseInt(value, 10, 32)
+
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		_, err = strconv.ParseInt(value, 10, 64)
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		_, err = strconv.ParseUint(value, 10, 32)
+
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		_, err = strconv.ParseUint(value, 10, 64)
+
+	case protoreflect.FloatKind:
+		_, err = strconv.ParseFloat(value, 32)
+
+	case protoreflect.DoubleKind:
+		_, err = strconv.ParseFloat(value, 64)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q for %q, %s expected", ErrInvalidFieldValue, value, path, fd.Kind())
+	}
+
+	return json.Number(value), nil
+}
@@ -0,0 +1,155 @@
+package grpcsteps
+
+import (
+	"testing"
+
+	"github.com/cucumber/godog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestTablePayload(t *testing.T) {
+	t.Parallel()
+
+	fileDescriptor := messageType{descriptor: (&descriptorpb.FileDescriptorProto{}).ProtoReflect().Descriptor()}
+	structs := messageType{descriptor: (&structpb.Struct{}).ProtoReflect().Descriptor(), stream: true}
+
+	testCases := []struct {
+		scenario        string
+		messageType     messageType
+		table           *godog.Table
+		expectedPayload string
+		expectedError   string
+	}{
+		{
+			scenario:    "message",
+			messageType: fileDescriptor,
+			table: newTable(
+				[]string{"name", "service.proto"},
+				[]string{"dependency[0]", "empty.proto"},
+				[]string{"dependency[1]", "timestamp.proto"},
+				[]string{"message_type[0].field[0].label", "LABEL_OPTIONAL"},
+				[]string{"message_type[0].field[0].number", "1"},
+				[]string{"options.java_multiple_files", "true"},
+				[]string{"options.optimizeFor", "2"},
+				[]string{"syntax", "<ignore-diff>"},
+			),
+			expectedPayload: `{"dependency":["empty.proto","timestamp.proto"],"message_type":[{"field":[{"label":1,"number":1}]}],"name":"service.proto","options":{"java_multiple_files":true,"optimize_for":2},"syntax":"<ignore-diff>"}`,
+		},
+		{
+			scenario:    "message in json",
+			messageType: fileDescriptor,
+			table: newTable(
+				[]string{"options", `{"java_package": "grpctest"}`},
+				[]string{"public_dependency", `[1, 2]`},
+			),
+			expectedPayload: `{"options":{"java_package":"grpctest"},"public_dependency":[1,2]}`,
+		},
+		{
+			scenario:    "stream",
+			messageType: structs,
+			table: newTable(
+				[]string{"fields.name.string_value", "fields.count.number_value"},
+				[]string{"foo", "1.5"},
+				[]string{"bar", ""},
+			),
+			expectedPayload: `[{"fields":{"count":{"number_value":1.5},"name":{"string_value":"foo"}}},{"fields":{"name":{"string_value":"bar"}}}]`,
+		},
+		{
+			scenario:      "unknown field",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"unknown", "foo"}),
+			expectedError: `unknown field: "unknown" in google.protobuf.FileDescriptorProto`,
+		},
+		{
+			scenario:      "unknown nested field",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"options.unknown", "foo"}),
+			expectedError: `unknown field: "options.unknown" in google.protobuf.FileOptions`,
+		},
+		{
+			scenario:      "invalid path",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"name[", "foo"}),
+			expectedError: `invalid field path: "name["`,
+		},
+		{
+			scenario:      "index of a non list",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"name[0]", "foo"}),
+			expectedError: `invalid field path: "name[0]", name is not a list`,
+		},
+		{
+			scenario:      "gap in the indexes",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"dependency[0]", "empty.proto"}, []string{"dependency[2]", "timestamp.proto"}),
+			expectedError: `invalid field path: "dependency[2]", the next index of dependency is 1`,
+		},
+		{
+			scenario:      "field of a list",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"message_type.name", "foo"}),
+			expectedError: `invalid field path: "message_type.name", message_type is a list`,
+		},
+		{
+			scenario:      "field of a scalar",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"name.value", "foo"}),
+			expectedError: `invalid field path: "name.value", name is not a message`,
+		},
+		{
+			scenario:      "invalid value",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"public_dependency[0]", "foo"}),
+			expectedError: `invalid field value: "foo" for "public_dependency[0]", int32 expected`,
+		},
+		{
+			scenario:      "invalid enum",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"options.optimize_for", "FAST"}),
+			expectedError: `invalid field value: "FAST" for "options.optimize_for", enum expected`,
+		},
+		{
+			scenario:      "invalid json",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"options", "{"}),
+			expectedError: `invalid field value: "{" for "options", options expects JSON`,
+		},
+		{
+			scenario:      "wrong number of cells in message",
+			messageType:   fileDescriptor,
+			table:         newTable([]string{"name", "foo", "bar"}),
+			expectedError: `invalid table: row 1 has 3 cells, a field path and a value expected`,
+		},
+		{
+			scenario:      "wrong number of cells in stream",
+			messageType:   structs,
+			table:         newTable([]string{"fields.name.string_value"}, []string{"foo", "bar"}),
+			expectedError: `invalid table: row 2 has 2 cells, 1 expected`,
+		},
+		{
+			scenario:      "missing field paths",
+			messageType:   structs,
+			table:         newTable(),
+			expectedError: `invalid table: the field paths are missing`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			payload, err := tablePayload(tc.messageType, tc.table)
+
+			assert.Equal(t, tc.expectedPayload, payload)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
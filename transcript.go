@@ -0,0 +1,309 @@
+package grpcsteps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cucumber/godog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// transcriptSourceClient is the source of the requests that the client sends, the mocked services use their id.
+const transcriptSourceClient = "client"
+
+// transcriptAttachment is the name of the transcript that is attached to the cucumber report of a failed scenario.
+const transcriptAttachment = "grpc-transcript.json"
+
+// transcriptEntry is a grpc call of a scenario, sent by the client or received by a mocked service.
+type transcriptEntry struct {
+	// Source is "client" for the requests that the client sends, or the id of the mocked service that receives them.
+	Source         string          `json:"source"`
+	Method         string          `json:"method"`
+	Address        string          `json:"address,omitempty"`
+	Header         metadata.MD     `json:"header,omitempty"`
+	ResponseHeader metadata.MD     `json:"responseHeader,omitempty"`
+	Request        json.RawMessage `json:"request,omitempty"`
+	Response       json.RawMessage `json:"response,omitempty"`
+	Code           codes.Code      `json:"code"`
+	Message        string          `json:"message,omitempty"`
+	Duration       time.Duration   `json:"duration"`
+}
+
+// MarshalJSON writes the code and the duration in a readable form.
+func (e transcriptEntry) MarshalJSON() ([]byte, error) {
+	type entry transcriptEntry
+
+	return json.Marshal(struct {
+		entry
+		Code     string `json:"code"`
+		Duration string `json:"duration"`
+	}{
+		entry:    entry(e),
+		Code:     e.Code.String(),
+		Duration: e.Duration.String(),
+	})
+}
+
+// transcript keeps the grpc calls of a scenario, the client and the mocked services of the scenario share it.
+type transcript struct {
+	scenario  string
+	entries   []transcriptEntry
+	observers []func(scenario string, e transcriptEntry)
+	output    io.Writer
+	reported  bool
+
+	mu sync.Mutex
+}
+
+// Observe calls the function with every grpc call that is recorded after.
+func (t *transcript) Observe(fn func(scenario string, e transcriptEntry)) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.observers = append(t.observers, fn)
+}
+
+// Record adds a grpc call to the transcript, the transcript could be nil if the call is not sent by a scenario.
+func (t *transcript) Record(e transcriptEntry) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.entries = append(t.entries, e)
+	observers := t.observers
+	t.mu.Unlock()
+
+	for _, fn := range observers {
+		fn(t.scenario, e)
+	}
+}
+
+func (t *transcript) Entries() []transcriptEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]transcriptEntry(nil), t.entries...)
+}
+
+// SetOutput sets where the transcript is written when the scenario fails, see reportFailure().
+func (t *transcript) SetOutput(w io.Writer) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.output = w
+}
+
+// reportFailure reports the transcript to its output, the client and the external service manager both report it when
+// the scenario fails, the first one wins.
+func (t *transcript) reportFailure(ctx context.Context) context.Context {
+	if t == nil {
+		return ctx
+	}
+
+	t.mu.Lock()
+	out := t.output
+	t.mu.Unlock()
+
+	return t.Report(ctx, out)
+}
+
+// Report writes the transcript to the output and attaches it to the cucumber report, once per scenario.
+func (t *transcript) Report(ctx context.Context, out io.Writer) context.Context {
+	if t == nil {
+		return ctx
+	}
+
+	t.mu.Lock()
+	reported := t.reported
+	t.reported = true
+	t.mu.Unlock()
+
+	entries := t.Entries()
+
+	if reported || len(entries) == 0 {
+		return ctx
+	}
+
+	if out != nil {
+		_, _ = fmt.Fprint(out, formatTranscript(t.scenario, entries)) // nolint: errcheck
+	}
+
+	payload, err := json.MarshalIndent(entries, "", "    ")
+	if err != nil {
+		return ctx
+	}
+
+	return godog.Attach(ctx, godog.Attachment{
+		Body:      payload,
+		FileName:  transcriptAttachment,
+		MediaType: "application/json",
+	})
+}
+
+// formatTranscript formats the grpc calls of a scenario, one per paragraph.
+func formatTranscript(scenario string, entries []transcriptEntry) string {
+	var sb strings.Builder
+
+	_, _ = fmt.Fprintf(&sb, "grpc transcript of scenario %q:\n", scenario)
+
+	for i, e := range entries {
+		arrow := "<-"
+
+		if e.Source == transcriptSourceClient {
+			arrow = "->"
+		}
+
+		_, _ = fmt.Fprintf(&sb, "\n%d. %s %s %s", i+1, e.Source, arrow, e.Method)
+
+		if e.Address != "" {
+			_, _ = fmt.Fprintf(&sb, " (%s)", e.Address)
+		}
+
+		_, _ = fmt.Fprintf(&sb, " %s", e.Code)
+
+		if e.Message != "" {
+			_, _ = fmt.Fprintf(&sb, " %q", e.Message)
+		}
+
+		_, _ = fmt.Fprintf(&sb, " in %s\n", e.Duration)
+
+		writeTranscriptHeader(&sb, "header", e.Header)
+
+		if len(e.Request) > 0 {
+			_, _ = fmt.Fprintf(&sb, "   request: %s\n", e.Request)
+		}
+
+		writeTranscriptHeader(&sb, "response header", e.ResponseHeader)
+
+		if len(e.Response) > 0 {
+			_, _ = fmt.Fprintf(&sb, "   response: %s\n", e.Response)
+		}
+	}
+
+	return sb.String()
+}
+
+func writeTranscriptHeader(sb *strings.Builder, name string, header metadata.MD) {
+	if len(header) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(header))
+
+	for k := range header {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+
+	for _, k := range keys {
+		for _, v := range header[k] {
+			values = append(values, fmt.Sprintf("%s=%q", k, v))
+		}
+	}
+
+	_, _ = fmt.Fprintf(sb, "   %s: %s\n", name, strings.Join(values, ", "))
+}
+
+// newTranscriptEntry creates the entry of a grpc call that ended with the error, if any.
+func newTranscriptEntry(source, method string, header metadata.MD, err error, d time.Duration) transcriptEntry {
+	st := status.Convert(err)
+
+	return transcriptEntry{
+		Source:   source,
+		Method:   method,
+		Header:   header.Copy(),
+		Code:     st.Code(),
+		Message:  st.Message(),
+		Duration: d,
+	}
+}
+
+// transcriptPayload marshals a message, or a slice of messages, to compact JSON. The proto messages are marshaled with
+// protojson.
+func transcriptPayload(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+
+	if msg, ok := v.(proto.Message); ok {
+		payload, err := protojson.Marshal(msg)
+		if err != nil {
+			return nil
+		}
+
+		var buf bytes.Buffer
+
+		if err := json.Compact(&buf, payload); err != nil {
+			return nil
+		}
+
+		return buf.Bytes()
+	}
+
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Slice {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		messages := make([]json.RawMessage, 0, rv.Len())
+
+		for i := 0; i < rv.Len(); i++ {
+			messages = append(messages, transcriptPayload(rv.Index(i).Interface()))
+		}
+
+		v = messages
+	}
+
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	return payload
+}
+
+type transcriptCtxKey struct{}
+
+// withTranscript gives the scenario a transcript, unless it already has one.
+func withTranscript(ctx context.Context, sc *godog.Scenario) (context.Context, *transcript) {
+	if t := transcriptFromContext(ctx); t != nil {
+		return ctx, t
+	}
+
+	t := &transcript{scenario: sc.Name, output: os.Stderr}
+
+	return context.WithValue(ctx, transcriptCtxKey{}, t), t
+}
+
+func transcriptFromContext(ctx context.Context) *transcript {
+	t, _ := ctx.Value(transcriptCtxKey{}).(*transcript) // nolint: errcheck
+
+	return t
+}
@@ -0,0 +1,208 @@
+package grpcsteps
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/godogx/grpcsteps/internal/grpctest"
+)
+
+func newTestTranscript() *transcript {
+	t := &transcript{scenario: "Get item"}
+
+	t.Record(transcriptEntry{
+		Source:   "item-service",
+		Method:   "/grpctest.ItemService/GetItem",
+		Address:  "127.0.0.1:9090",
+		Header:   metadata.Pairs("locale", "en-US", "tag", "foo", "tag", "bar"),
+		Request:  []byte(`{"id":42}`),
+		Code:     codes.NotFound,
+		Message:  "item not found",
+		Duration: 2 * time.Millisecond,
+	})
+
+	t.Record(transcriptEntry{
+		Source:         transcriptSourceClient,
+		Method:         "/grpctest.ItemService/GetItem",
+		Header:         metadata.Pairs("locale", "en-US"),
+		ResponseHeader: metadata.Pairs("content-type", "application/grpc"),
+		Request:        []byte(`{"id":42}`),
+		Code:           codes.NotFound,
+		Message:        "item not found",
+		Duration:       3 * time.Millisecond,
+	})
+
+	return t
+}
+
+func TestFormatTranscript(t *testing.T) {
+	t.Parallel()
+
+	tr := newTestTranscript()
+
+	expected := `grpc transcript of scenario "Get item":
+
+1. item-service <- /grpctest.ItemService/GetItem (127.0.0.1:9090) NotFound "item not found" in 2ms
+   header: locale="en-US", tag="foo", tag="bar"
+   request: {"id":42}
+
+2. client -> /grpctest.ItemService/GetItem NotFound "item not found" in 3ms
+   header: locale="en-US"
+   request: {"id":42}
+   response header: content-type="application/grpc"
+`
+
+	assert.Equal(t, expected, formatTranscript(tr.scenario, tr.Entries()))
+}
+
+func TestTranscript_Report(t *testing.T) {
+	t.Parallel()
+
+	tr := newTestTranscript()
+
+	var buf bytes.Buffer
+
+	ctx := tr.Report(context.Background(), &buf)
+
+	assert.Equal(t, formatTranscript(tr.scenario, tr.Entries()), buf.String())
+
+	attachments := godog.Attachments(ctx)
+
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "grpc-transcript.json", attachments[0].FileName)
+	assert.Equal(t, "application/json", attachments[0].MediaType)
+
+	expected := `[
+    {
+        "source": "item-service",
+        "method": "/grpctest.ItemService/GetItem",
+        "address": "127.0.0.1:9090",
+        "header": {"locale": ["en-US"], "tag": ["foo", "bar"]},
+        "request": {"id": 42},
+        "code": "NotFound",
+        "message": "item not found",
+        "duration": "2ms"
+    },
+    {
+        "source": "client",
+        "method": "/grpctest.ItemService/GetItem",
+        "header": {"locale": ["en-US"]},
+        "responseHeader": {"content-type": ["application/grpc"]},
+        "request": {"id": 42},
+        "code": "NotFound",
+        "message": "item not found",
+        "duration": "3ms"
+    }
+]`
+
+	assert.JSONEq(t, expected, string(attachments[0].Body))
+
+	// The transcript is reported once.
+	buf.Reset()
+
+	ctx = tr.Report(context.Background(), &buf)
+
+	assert.Empty(t, buf.String())
+	assert.Empty(t, godog.Attachments(ctx))
+}
+
+func TestTranscript_ReportNothing(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	// No transcript.
+	ctx := (*transcript)(nil).Report(context.Background(), &buf)
+
+	assert.Empty(t, buf.String())
+	assert.Empty(t, godog.Attachments(ctx))
+
+	// No grpc request.
+	ctx = (&transcript{}).Report(context.Background(), &buf)
+
+	assert.Empty(t, buf.String())
+	assert.Empty(t, godog.Attachments(ctx))
+
+	// No output.
+	ctx = newTestTranscript().Report(context.Background(), nil)
+
+	assert.Len(t, godog.Attachments(ctx), 1)
+}
+
+func TestTranscriptPayload(t *testing.T) {
+	t.Parallel()
+
+	item := &grpctest.Item{Id: 42, Name: "Item #42", CreateTime: timestamppb.New(time.Unix(1700000000, 0))}
+
+	testCases := []struct {
+		scenario string
+		payload  interface{}
+		expected string
+	}{
+		{
+			scenario: "nil",
+		},
+		{
+			scenario: "message",
+			payload:  item,
+			expected: `{"id":42,"name":"Item #42","createTime":"2023-11-14T22:13:20Z"}`,
+		},
+		{
+			scenario: "messages",
+			payload:  []*grpctest.Item{item, {Id: 43}},
+			expected: `[{"id":42,"name":"Item #42","createTime":"2023-11-14T22:13:20Z"},{"id":43}]`,
+		},
+		{
+			scenario: "pointer to messages",
+			payload:  &[]*grpctest.Item{{Id: 43}},
+			expected: `[{"id":43}]`,
+		},
+		{
+			scenario: "no message",
+			payload:  []interface{}{},
+			expected: `[]`,
+		},
+		{
+			scenario: "not a message",
+			payload:  map[string]interface{}{"id": 42},
+			expected: `{"id":42}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, string(transcriptPayload(tc.payload)))
+		})
+	}
+}
+
+func TestTranscriptInContext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// Case 1: no transcript in context.
+	assert.Nil(t, transcriptFromContext(ctx))
+
+	// Case 2: the scenario shares the transcript.
+	ctx, tr := withTranscript(ctx, &godog.Scenario{Name: "Get item"})
+
+	assert.Equal(t, "Get item", tr.scenario)
+	assert.Same(t, tr, transcriptFromContext(ctx))
+
+	_, other := withTranscript(ctx, &godog.Scenario{Name: "Get item"})
+
+	assert.Same(t, tr, other)
+}